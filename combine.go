@@ -0,0 +1,165 @@
+package grid_to_isobands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// ErrGridMismatch is wrapped by the errors Combine and RegridTransformer
+// return for grids of different sizes or coordinates.
+var ErrGridMismatch = errors.New("grids do not match")
+
+// CellFunc derives the value at one point from the values of several grids
+// there, in the order the grids were given. The slice is reused between
+// points, so a CellFunc must not keep it.
+type CellFunc func(values []float64) float64
+
+// Combine derives a new grid from several aligned ones, such as the u and v
+// components of the wind from two GRIB messages, by calling f at every
+// point. The grids must be valid (see Validate) and have the same size and
+// the same coordinates, which the result shares with the first, and those
+// with a Unit must share it. The
+// result keeps that unit; callers whose f changes it should set Unit
+// themselves. It stops with ctx.Err() if ctx is cancelled.
+func Combine(ctx context.Context, f CellFunc, grids ...*GridValues) (*GridValues, error) {
+	if len(grids) == 0 {
+		return nil, fmt.Errorf("no grids to combine")
+	}
+	for i, grid := range grids {
+		if err := grid.Validate(); err != nil {
+			return nil, fmt.Errorf("error combining grid %d: %w", i, err)
+		}
+	}
+	first := grids[0]
+	for i, grid := range grids[1:] {
+		if err := checkAligned(first, grid); err != nil {
+			return nil, fmt.Errorf("error combining grid %d with grid 0: %w", i+1, err)
+		}
+	}
+
+	result := &GridValues{
+		SizeX:    first.SizeX,
+		SizeY:    first.SizeY,
+		Values:   make([]float64, len(first.Values)),
+		Lats:     slices.Clone(first.Lats),
+		Lons:     slices.Clone(first.Lons),
+		Geometry: first.Geometry,
+	}
+	for _, grid := range grids {
+		if grid.Unit != "" {
+			result.Unit = grid.Unit
+		}
+	}
+	cell := make([]float64, len(grids))
+	for y := 0; y < first.SizeY; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for i := y * first.SizeX; i < (y+1)*first.SizeX; i++ {
+			for k, grid := range grids {
+				cell[k] = grid.Values[i]
+			}
+			result.Values[i] = f(cell)
+		}
+	}
+	return result, nil
+}
+
+// checkAligned reports grids whose points aren't in the same places, or
+// whose units differ. Grids described the same way are compared directly;
+// otherwise every point is, so both grids must be valid.
+func checkAligned(a, b *GridValues) error {
+	if a.SizeX != b.SizeX || a.SizeY != b.SizeY {
+		return fmt.Errorf("%w: %dx%d and %dx%d", ErrGridMismatch, a.SizeX, a.SizeY, b.SizeX, b.SizeY)
+	}
+	if a.Unit != "" && b.Unit != "" && a.Unit.canonical() != b.Unit.canonical() {
+		return fmt.Errorf("%w: units %v and %v", ErrGridMismatch, a.Unit, b.Unit)
+	}
+	if a.Geometry != nil && reflect.DeepEqual(a.Geometry, b.Geometry) {
+		return nil
+	}
+	if a.Geometry == nil && b.Geometry == nil && slices.Equal(a.Lats, b.Lats) && slices.Equal(a.Lons, b.Lons) {
+		return nil
+	}
+	const tolerance = 1e-9
+	latLonA, latLonB := a.latLons(), b.latLons()
+	for y := 0; y < a.SizeY; y++ {
+		for x := 0; x < a.SizeX; x++ {
+			latA, lonA := latLonA(x, y)
+			latB, lonB := latLonB(x, y)
+			if math.Abs(latA-latB) > tolerance || math.Abs(math.Remainder(lonA-lonB, 360)) > tolerance {
+				return fmt.Errorf("%w: point %d,%d is at %v,%v and %v,%v", ErrGridMismatch, x, y, latA, lonA, latB, lonB)
+			}
+		}
+	}
+	return nil
+}
+
+// Magnitude returns the length of the vector with components u and v at
+// every point, e.g. wind speed from its u and v components.
+func Magnitude(ctx context.Context, u, v *GridValues) (*GridValues, error) {
+	return Combine(ctx, func(values []float64) float64 {
+		return math.Hypot(values[0], values[1])
+	}, u, v)
+}
+
+// Difference returns a minus b at every point, e.g. the change between two
+// forecast hours.
+func Difference(ctx context.Context, a, b *GridValues) (*GridValues, error) {
+	return Combine(ctx, func(values []float64) float64 {
+		return values[0] - values[1]
+	}, a, b)
+}
+
+// Max returns the largest of the grids' values at every point, e.g. a
+// composite of several radars. NaN is missing, so a point is only NaN when
+// it is NaN in every grid.
+func Max(ctx context.Context, grids ...*GridValues) (*GridValues, error) {
+	return Combine(ctx, func(values []float64) float64 {
+		result := math.NaN()
+		for _, v := range values {
+			if !math.IsNaN(v) && !(v <= result) {
+				result = v
+			}
+		}
+		return result
+	}, grids...)
+}
+
+// Mean returns the average of the grids' values at every point, e.g. an
+// ensemble mean. NaN is missing, so a point averages the grids where it
+// isn't NaN, and is only NaN when it is NaN in every grid.
+func Mean(ctx context.Context, grids ...*GridValues) (*GridValues, error) {
+	return Combine(ctx, func(values []float64) float64 {
+		var sum float64
+		n := 0
+		for _, v := range values {
+			if !math.IsNaN(v) {
+				sum += v
+				n++
+			}
+		}
+		if n == 0 {
+			return math.NaN()
+		}
+		return sum / float64(n)
+	}, grids...)
+}
+
+// Scale returns value*factor + offset at every point of a grid, such as a
+// conversion between units, e.g. factor 1.9438 for m/s to knots. The result
+// has no Unit; see Unit.Conversion and UnitTransformer for known units.
+func Scale(ctx context.Context, grid *GridValues, factor, offset float64) (*GridValues, error) {
+	scaled, err := Combine(ctx, func(values []float64) float64 {
+		return values[0]*factor + offset
+	}, grid)
+	if err != nil {
+		return nil, err
+	}
+	scaled.Unit = ""
+	return scaled, nil
+}
@@ -0,0 +1,104 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+)
+
+func windGrid(values ...float64) *grid_to_isobands.GridValues {
+	return &grid_to_isobands.GridValues{
+		SizeX:    2,
+		SizeY:    2,
+		Values:   values,
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 40, Lon0: 250, DLat: -0.25, DLon: 0.25},
+	}
+}
+
+// pair adapts a combinator of any number of grids to two.
+func pair(combine func(context.Context, ...*grid_to_isobands.GridValues) (*grid_to_isobands.GridValues, error)) func(context.Context, *grid_to_isobands.GridValues, *grid_to_isobands.GridValues) (*grid_to_isobands.GridValues, error) {
+	return func(ctx context.Context, a, b *grid_to_isobands.GridValues) (*grid_to_isobands.GridValues, error) {
+		return combine(ctx, a, b)
+	}
+}
+
+func TestCombineBuiltins(t *testing.T) {
+	nan := math.NaN()
+	u := windGrid(3, -6, 0, nan)
+	v := windGrid(4, 8, -2, 1)
+	tests := []struct {
+		name    string
+		combine func(ctx context.Context, a, b *grid_to_isobands.GridValues) (*grid_to_isobands.GridValues, error)
+		expect  []float64
+	}{
+		{name: `magnitude`, combine: grid_to_isobands.Magnitude, expect: []float64{5, 10, 2, nan}},
+		{name: `difference`, combine: grid_to_isobands.Difference, expect: []float64{-1, -14, 2, nan}},
+		{name: `max`, combine: pair(grid_to_isobands.Max), expect: []float64{4, 8, 0, 1}},
+		{name: `mean`, combine: pair(grid_to_isobands.Mean), expect: []float64{3.5, 1, -1, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := test.combine(context.Background(), u, v)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			for i, expected := range test.expect {
+				if math.IsNaN(expected) != math.IsNaN(actual.Values[i]) || (!math.IsNaN(expected) && expected != actual.Values[i]) {
+					t.Fatalf(`expected %v, got %v`, test.expect, actual.Values)
+				}
+			}
+			if actual.Geometry != u.Geometry {
+				t.Fatalf(`expected %v, got %v`, u.Geometry, actual.Geometry)
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	actual, err := grid_to_isobands.Scale(context.Background(), windGrid(4, 8, -2, 1), 2, 1)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if expected := []float64{9, 17, -3, 3}; !reflect.DeepEqual(actual.Values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, actual.Values)
+	}
+}
+
+func TestCombineComparesCoordinates(t *testing.T) {
+	regular := windGrid(1, 2, 3, 4)
+	// The same points given one by one, with longitudes in -180..180.
+	listed := &grid_to_isobands.GridValues{
+		SizeX:  2,
+		SizeY:  2,
+		Values: []float64{1, 1, 1, 1},
+		Lats:   []float64{40, 40, 39.75, 39.75},
+		Lons:   []float64{-110, -109.75, -110, -109.75},
+	}
+	if _, err := grid_to_isobands.Difference(context.Background(), regular, listed); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	listed.Lats[3] = 39.5
+	if _, err := grid_to_isobands.Difference(context.Background(), regular, listed); !errors.Is(err, grid_to_isobands.ErrGridMismatch) {
+		t.Fatalf(`expected %v, got %v`, grid_to_isobands.ErrGridMismatch, err)
+	}
+	var validationErr *grid_to_isobands.ValidationError
+	for name, grid := range map[string]*grid_to_isobands.GridValues{
+		`without coordinates`: {SizeX: 2, SizeY: 2, Values: []float64{1, 1, 1, 1}},
+		`with short coordinates`: {SizeX: 2, SizeY: 2, Values: []float64{1, 1, 1, 1},
+			Lats: []float64{40, 40}, Lons: []float64{-110, -109.75}},
+		`with short axes`: {SizeX: 2, SizeY: 2, Values: []float64{1, 1, 1, 1},
+			Geometry: grid_to_isobands.LatLonAxes{Lats: []float64{40}, Lons: []float64{-110}}},
+	} {
+		if _, err := grid_to_isobands.Difference(context.Background(), regular, grid); !errors.As(err, &validationErr) {
+			t.Fatalf(`%v: expected a validation error, got %v`, name, err)
+		}
+	}
+	wide := &grid_to_isobands.GridValues{SizeX: 4, SizeY: 1, Values: []float64{1, 2, 3, 4}, Geometry: regular.Geometry}
+	if _, err := grid_to_isobands.Max(context.Background(), regular, wide); !errors.Is(err, grid_to_isobands.ErrGridMismatch) {
+		t.Fatalf(`expected %v, got %v`, grid_to_isobands.ErrGridMismatch, err)
+	}
+}
@@ -0,0 +1,207 @@
+package grid_to_isobands
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// The geodesic transformers size their windows in kilometers on the ground
+// rather than in grid cells. On a regular lat/lon grid a fixed number of
+// cells covers half as much ground east-west at 60°N as at the equator, so
+// these work out the spacing of each row from its coordinates and widen
+// the window to match (see transformers.Footprint).
+
+// GeodesicGaussianTransformer smooths with a Gaussian whose standard
+// deviation is sigmaKm kilometers in every row.
+func GeodesicGaussianTransformer(sigmaKm float64, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if !(sigmaKm > 0) || math.IsInf(sigmaKm, 1) {
+			return fmt.Errorf("sigma must be a positive distance, got %v km", sigmaKm)
+		}
+		if err := values.Validate(); err != nil {
+			return err
+		}
+		dx, dy := cellSpacing(values)
+		sigmaX := make([]float64, values.SizeY)
+		for y := range sigmaX {
+			sigmaX[y] = cellsAcross(sigmaKm, dx[y], values.SizeX)
+		}
+		sigmaY := cellsAcross(sigmaKm, dy, values.SizeY)
+		smoothed, err := transformers.GaussianRows(ctx, values.Values, values.SizeX, values.SizeY, sigmaX, sigmaY, opts...)
+		if err != nil {
+			return fmt.Errorf("error applying geodesic gaussian filter: %w", err)
+		}
+		values.Values = smoothed
+		return nil
+	}
+}
+
+// GeodesicMedianTransformer applies a median filter over windows reaching
+// radiusKm kilometers either side of each point.
+func GeodesicMedianTransformer(radiusKm float64, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		f, err := geodesicFootprint(values, radiusKm)
+		if err != nil {
+			return err
+		}
+		filtered, err := transformers.MedianFootprint(ctx, values.Values, values.SizeX, values.SizeY, f, opts...)
+		if err != nil {
+			return fmt.Errorf("error applying geodesic median filter: %w", err)
+		}
+		values.Values = filtered
+		return nil
+	}
+}
+
+// GeodesicOpenCloseTransformer is OpenCloseTransformer over windows reaching
+// radiusKm kilometers either side of each point.
+func GeodesicOpenCloseTransformer(radiusKm float64, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		f, err := geodesicFootprint(values, radiusKm)
+		if err != nil {
+			return err
+		}
+		morphOps := transformers.NewMorphologicalOps(values.SizeX, values.SizeY, opts...)
+		opened, err := morphOps.OpenCloseFootprint(ctx, values.Values, f)
+		if err != nil {
+			return fmt.Errorf("error applying geodesic open-close: %w", err)
+		}
+		values.Values = opened
+		return nil
+	}
+}
+
+// GeodesicCloseOpenTransformer is CloseOpenTransformer over windows reaching
+// radiusKm kilometers either side of each point.
+func GeodesicCloseOpenTransformer(radiusKm float64, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		f, err := geodesicFootprint(values, radiusKm)
+		if err != nil {
+			return err
+		}
+		morphOps := transformers.NewMorphologicalOps(values.SizeX, values.SizeY, opts...)
+		closed, err := morphOps.CloseOpenFootprint(ctx, values.Values, f)
+		if err != nil {
+			return fmt.Errorf("error applying geodesic close-open: %w", err)
+		}
+		values.Values = closed
+		return nil
+	}
+}
+
+// GeodesicSpeckleTransformer is SpeckleTransformer with s.MinArea in square
+// kilometers, measuring each point by the spacing of its row.
+func GeodesicSpeckleTransformer(s transformers.Speckle, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := values.Validate(); err != nil {
+			return err
+		}
+		speckle := s
+		speckle.RowAreas = rowAreas(values)
+		cleaned, err := transformers.RemoveSpeckles(ctx, values.Values, values.SizeX, values.SizeY, speckle, opts...)
+		if err != nil {
+			return fmt.Errorf("error removing speckles: %w", err)
+		}
+		values.Values = cleaned
+		return nil
+	}
+}
+
+// GeodesicGapFillTransformer is GapFillTransformer with g.MaxArea in square
+// kilometers, measuring each point by the spacing of its row.
+func GeodesicGapFillTransformer(g transformers.GapFill, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := values.Validate(); err != nil {
+			return err
+		}
+		gapFill := g
+		gapFill.RowAreas = rowAreas(values)
+		filled, err := transformers.FillGaps(ctx, values.Values, values.SizeX, values.SizeY, gapFill, opts...)
+		if err != nil {
+			return fmt.Errorf("error filling gaps: %w", err)
+		}
+		values.Values = filled
+		return nil
+	}
+}
+
+// geodesicFootprint is the footprint reaching radiusKm kilometers either
+// side of each point of values, to the nearest cell.
+func geodesicFootprint(values *GridValues, radiusKm float64) (transformers.Footprint, error) {
+	if !(radiusKm >= 0) || math.IsInf(radiusKm, 1) {
+		return transformers.Footprint{}, fmt.Errorf("radius must be a non-negative distance, got %v km", radiusKm)
+	}
+	if err := values.Validate(); err != nil {
+		return transformers.Footprint{}, err
+	}
+	dx, dy := cellSpacing(values)
+	f := transformers.Footprint{
+		HalfWidths: make([]int, values.SizeY),
+		HalfHeight: halfCells(radiusKm, dy, values.SizeY),
+	}
+	for y := range f.HalfWidths {
+		f.HalfWidths[y] = halfCells(radiusKm, dx[y], values.SizeX)
+	}
+	return f, nil
+}
+
+// cellSpacing returns the ground distance in km between neighboring columns
+// of each row, and the mean distance between neighboring rows, both measured
+// at the middle column. An axis of one cell has a spacing of zero.
+func cellSpacing(values *GridValues) (dx []float64, dy float64) {
+	x := min(values.SizeX/2, max(values.SizeX-2, 0))
+	dx = make([]float64, values.SizeY)
+	if values.SizeX > 1 {
+		for y := range dx {
+			dx[y] = distanceKm(values, x, y, x+1, y)
+		}
+	}
+	if values.SizeY > 1 {
+		for y := 0; y < values.SizeY-1; y++ {
+			dy += distanceKm(values, x, y, x, y+1)
+		}
+		dy /= float64(values.SizeY - 1)
+	}
+	return dx, dy
+}
+
+// rowAreas returns the ground area in km² of a cell in each row.
+func rowAreas(values *GridValues) []float64 {
+	dx, dy := cellSpacing(values)
+	areas := make([]float64, values.SizeY)
+	for y := range areas {
+		areas[y] = dx[y] * dy
+	}
+	return areas
+}
+
+// cellsAcross converts km into a number of cells of the given spacing along
+// an axis of n cells. It is capped at n, which already spans the whole axis,
+// and a spacing of zero, as at a pole, gives n.
+func cellsAcross(km, spacing float64, n int) float64 {
+	if !(spacing > 0) {
+		return float64(n)
+	}
+	return min(km/spacing, float64(n))
+}
+
+// halfCells is the half-width in whole cells of a window reaching km either
+// side along an axis of n cells, capped at n-1, which already reaches every
+// cell.
+func halfCells(km, spacing float64, n int) int {
+	return min(int(math.Round(cellsAcross(km, spacing, n))), n-1)
+}
+
+// distanceKm is the great-circle distance between two points of values.
+func distanceKm(values *GridValues, x1, y1, x2, y2 int) float64 {
+	lat1, lon1 := values.LatLon(x1, y1)
+	lat2, lon2 := values.LatLon(x2, y2)
+	phi1, phi2 := radians(lat1), radians(lat2)
+	sinDLat := math.Sin((phi2 - phi1) / 2)
+	sinDLon := math.Sin(radians(lon2-lon1) / 2)
+	h := sinDLat*sinDLat + math.Cos(phi1)*math.Cos(phi2)*sinDLon*sinDLon
+	return 2 * EarthRadius / 1000 * math.Asin(math.Sqrt(min(h, 1)))
+}
@@ -0,0 +1,109 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// stripeGrid returns a 1° grid from the equator to 62°N with a stripe of
+// value 10 down columns first up to first+width-1, and 0 elsewhere.
+func stripeGrid(sizeX, first, width int) *grid_to_isobands.GridValues {
+	sizeY := 63
+	values := &grid_to_isobands.GridValues{
+		SizeX:    sizeX,
+		SizeY:    sizeY,
+		Values:   make([]float64, sizeX*sizeY),
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 0, Lon0: 0, DLat: 1, DLon: 1},
+	}
+	for y := range sizeY {
+		for x := first; x < first+width; x++ {
+			values.Values[y*sizeX+x] = 10
+		}
+	}
+	return values
+}
+
+func TestGeodesicWindowsWidenTowardsThePole(t *testing.T) {
+	// 115 km is one cell at the equator, but two east-west at 60°N, where
+	// meridians are half as far apart.
+	tests := []struct {
+		name        string
+		transform   grid_to_isobands.GridTransformer
+		stripeWidth int
+	}{
+		{name: `median`, transform: grid_to_isobands.GeodesicMedianTransformer(115), stripeWidth: 2},
+		{name: `open-close`, transform: grid_to_isobands.GeodesicOpenCloseTransformer(115), stripeWidth: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := stripeGrid(20, 8, test.stripeWidth)
+			if err := test.transform(context.Background(), values); err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			if actual := values.Values[1*20+9]; actual != 10 {
+				t.Fatalf(`expected the stripe to survive near the equator, got %v`, actual)
+			}
+			if actual := values.Values[60*20+9]; actual != 0 {
+				t.Fatalf(`expected the stripe to be removed at 60°N, got %v`, actual)
+			}
+		})
+	}
+}
+
+func TestGeodesicGaussianWidensTowardsThePole(t *testing.T) {
+	values := stripeGrid(81, 40, 1)
+	transform := grid_to_isobands.GeodesicGaussianTransformer(200)
+	if err := transform(context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	spread := func(y int) float64 {
+		var sum, moment float64
+		for x := range 81 {
+			v := values.Values[y*81+x]
+			sum += v
+			moment += v * float64((x-40)*(x-40))
+		}
+		return math.Sqrt(moment / sum)
+	}
+	if actual := spread(60) / spread(1); math.Abs(actual-2) > 0.1 {
+		t.Fatalf(`expected the spread at 60°N to be twice that near the equator, got %v times`, actual)
+	}
+}
+
+func TestGeodesicTransformersRejectBadRadius(t *testing.T) {
+	for _, transform := range []grid_to_isobands.GridTransformer{
+		grid_to_isobands.GeodesicGaussianTransformer(0),
+		grid_to_isobands.GeodesicMedianTransformer(-1),
+		grid_to_isobands.GeodesicCloseOpenTransformer(math.NaN()),
+	} {
+		if err := transform(context.Background(), stripeGrid(5, 1, 1)); err == nil {
+			t.Fatalf(`expected an error, got nil`)
+		}
+	}
+}
+
+func TestGeodesicSpeckleMeasuresKm2(t *testing.T) {
+	// Two 1° cells cover about 24,700 km² at the equator but only about
+	// 12,400 km² at 60°N.
+	values := stripeGrid(20, 0, 0)
+	values.Values[1*20+5], values.Values[1*20+6] = 30, 30
+	values.Values[60*20+5], values.Values[60*20+6] = 30, 30
+	transform := grid_to_isobands.GeodesicSpeckleTransformer(transformers.Speckle{
+		Member:      transformers.GreaterThan(20),
+		MinArea:     20000,
+		Replacement: math.NaN(),
+	})
+	if err := transform(context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if actual := values.Values[1*20+5]; actual != 30 {
+		t.Fatalf(`expected the equatorial region to stay, got %v`, actual)
+	}
+	if actual := values.Values[60*20+5]; !math.IsNaN(actual) {
+		t.Fatalf(`expected the region at 60°N to be removed, got %v`, actual)
+	}
+}
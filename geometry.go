@@ -0,0 +1,132 @@
+package grid_to_isobands
+
+import (
+	"slices"
+)
+
+// GridGeometry describes where a grid's points are without storing a latitude
+// and longitude for every point. A GridValues with a Geometry leaves Lats and
+// Lons empty; they are only expanded when a transformer needs per-point
+// coordinates (see GridValues.ExpandCoordinates).
+type GridGeometry interface {
+	// LatLon returns the coordinates of the point at column x, row y.
+	LatLon(x, y int) (lat, lon float64)
+}
+
+// RegularLatLon is an evenly spaced lat/lon grid. Lat0 and Lon0 are the
+// coordinates of the first point (column 0, row 0); DLat and DLon are the
+// signed steps between rows and columns.
+type RegularLatLon struct {
+	Lat0 float64
+	Lon0 float64
+	DLat float64
+	DLon float64
+}
+
+func (g RegularLatLon) LatLon(x, y int) (float64, float64) {
+	return g.Lat0 + float64(y)*g.DLat, g.Lon0 + float64(x)*g.DLon
+}
+
+// LatLonAxes is a rectilinear grid where every point in row y has latitude
+// Lats[y] and every point in column x has longitude Lons[x].
+type LatLonAxes struct {
+	Lats []float64
+	Lons []float64
+}
+
+func (g LatLonAxes) LatLon(x, y int) (float64, float64) {
+	return g.Lats[y], g.Lons[x]
+}
+
+// toAxes expands a regular geometry into its 1D axes. Geometries that aren't
+// rectilinear return false.
+func toAxes(geometry GridGeometry, sizeX, sizeY int) (LatLonAxes, bool) {
+	switch g := geometry.(type) {
+	case RegularLatLon:
+		axes := LatLonAxes{Lats: make([]float64, sizeY), Lons: make([]float64, sizeX)}
+		for y := range axes.Lats {
+			axes.Lats[y], _ = g.LatLon(0, y)
+		}
+		for x := range axes.Lons {
+			_, axes.Lons[x] = g.LatLon(x, 0)
+		}
+		return axes, true
+	case LatLonAxes:
+		return LatLonAxes{Lats: slices.Clone(g.Lats), Lons: slices.Clone(g.Lons)}, true
+	default:
+		return LatLonAxes{}, false
+	}
+}
+
+// LatLon returns the coordinates of the point at column x, row y, reading
+// them from Geometry when it is set and from Lats/Lons otherwise.
+func (g *GridValues) LatLon(x, y int) (lat, lon float64) {
+	if g.Geometry != nil {
+		return g.Geometry.LatLon(x, y)
+	}
+	i := y*g.SizeX + x
+	return g.Lats[i], g.Lons[i]
+}
+
+// latLons returns LatLon with the grid's projection prepared, for loops
+// that find many points.
+func (g *GridValues) latLons() func(x, y int) (lat, lon float64) {
+	if g.Geometry == nil {
+		return g.LatLon
+	}
+	return prepareGeometry(g.Geometry).LatLon
+}
+
+// ExpandCoordinates fills Lats and Lons with one entry per point from
+// Geometry, then clears Geometry. It does nothing if Geometry is not set.
+func (g *GridValues) ExpandCoordinates() {
+	if g.Geometry == nil {
+		return
+	}
+	size := g.SizeX * g.SizeY
+	g.Lats = make([]float64, size)
+	g.Lons = make([]float64, size)
+	latLon := g.latLons()
+	for y := 0; y < g.SizeY; y++ {
+		for x := 0; x < g.SizeX; x++ {
+			i := y*g.SizeX + x
+			g.Lats[i], g.Lons[i] = latLon(x, y)
+		}
+	}
+	g.Geometry = nil
+}
+
+// CompactCoordinates replaces per-point Lats and Lons with a LatLonAxes
+// geometry when every row shares one latitude and every column one
+// longitude. It reports whether the grid uses a Geometry afterwards.
+func (g *GridValues) CompactCoordinates() bool {
+	if g.Geometry != nil {
+		return true
+	}
+	axes, ok := rectilinearAxes(g.Lats, g.Lons, g.SizeX, g.SizeY)
+	if !ok {
+		return false
+	}
+	g.Geometry = axes
+	g.Lats, g.Lons = nil, nil
+	return true
+}
+
+// rectilinearAxes extracts the 1D axes from per-point coordinates, returning
+// false unless they match exactly on every row and column.
+func rectilinearAxes(lats, lons []float64, sizeX, sizeY int) (LatLonAxes, bool) {
+	if sizeX <= 0 || sizeY <= 0 || len(lats) != sizeX*sizeY || len(lons) != sizeX*sizeY {
+		return LatLonAxes{}, false
+	}
+	axes := LatLonAxes{Lats: make([]float64, sizeY), Lons: slices.Clone(lons[:sizeX])}
+	for y := 0; y < sizeY; y++ {
+		row := y * sizeX
+		axes.Lats[y] = lats[row]
+		for x := 0; x < sizeX; x++ {
+			if lats[row+x] != axes.Lats[y] || lons[row+x] != axes.Lons[x] {
+				return LatLonAxes{}, false
+			}
+		}
+	}
+	return axes, true
+}
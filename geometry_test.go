@@ -0,0 +1,120 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/skysparq/grid-to-isobands"
+)
+
+func TestExpandCoordinates(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:    3,
+		SizeY:    2,
+		Values:   []float64{1, 2, 3, 4, 5, 6},
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 50, Lon0: -100, DLat: -0.5, DLon: 0.25},
+	}
+	values.ExpandCoordinates()
+	expected := &grid_to_isobands.GridValues{
+		SizeX:  3,
+		SizeY:  2,
+		Values: []float64{1, 2, 3, 4, 5, 6},
+		Lats: []float64{
+			50, 50, 50,
+			49.5, 49.5, 49.5,
+		},
+		Lons: []float64{
+			-100, -99.75, -99.5,
+			-100, -99.75, -99.5,
+		},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, values)
+	}
+}
+
+func TestCompactCoordinates(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:  3,
+		SizeY:  2,
+		Values: []float64{1, 2, 3, 4, 5, 6},
+		Lats: []float64{
+			50, 50, 50,
+			49.5, 49.5, 49.5,
+		},
+		Lons: []float64{
+			-100, -99.75, -99.5,
+			-100, -99.75, -99.5,
+		},
+	}
+	if !values.CompactCoordinates() {
+		t.Fatal(`expected rectilinear coordinates to compact`)
+	}
+	expected := grid_to_isobands.LatLonAxes{
+		Lats: []float64{50, 49.5},
+		Lons: []float64{-100, -99.75, -99.5},
+	}
+	if !reflect.DeepEqual(values.Geometry, expected) || values.Lats != nil || values.Lons != nil {
+		t.Fatalf(`expected geometry %v, got %v`, expected, values)
+	}
+
+	curvilinear := &grid_to_isobands.GridValues{
+		SizeX:  2,
+		SizeY:  2,
+		Values: []float64{1, 2, 3, 4},
+		Lats:   []float64{50, 50.1, 49, 49.1},
+		Lons:   []float64{-100, -99, -100, -99},
+	}
+	if curvilinear.CompactCoordinates() {
+		t.Fatal(`expected curvilinear coordinates to stay expanded`)
+	}
+}
+
+// Transformers must leave a grid described by a Geometry with the same
+// coordinates as the equivalent grid with per-point Lats and Lons.
+func TestGeometryTransformsMatchExpanded(t *testing.T) {
+	transforms := map[string]grid_to_isobands.GridTransformer{
+		`swap`:    grid_to_isobands.SwapRightAndLeftTransformer(),
+		`reverse`: grid_to_isobands.ReverseVerticalTransformer(),
+		`crop`:    grid_to_isobands.CropTransformer(orb.Bound{Min: orb.Point{-99.8, 48}, Max: orb.Point{-99, 49.6}}),
+	}
+	geometries := map[string]grid_to_isobands.GridGeometry{
+		`regular`: grid_to_isobands.RegularLatLon{Lat0: 50, Lon0: -100, DLat: -0.5, DLon: 0.25},
+		`axes`: grid_to_isobands.LatLonAxes{
+			Lats: []float64{50, 49.5, 49, 48.5},
+			Lons: []float64{-100, -99.75, -99.5, -99.25, -99},
+		},
+	}
+	for transformName, transform := range transforms {
+		for geometryName, geometry := range geometries {
+			compact := &grid_to_isobands.GridValues{
+				SizeX:    5,
+				SizeY:    4,
+				Values:   make([]float64, 20),
+				Geometry: geometry,
+			}
+			for i := range compact.Values {
+				compact.Values[i] = float64(i)
+			}
+			expanded := &grid_to_isobands.GridValues{
+				SizeX:    compact.SizeX,
+				SizeY:    compact.SizeY,
+				Values:   append([]float64(nil), compact.Values...),
+				Geometry: geometry,
+			}
+			expanded.ExpandCoordinates()
+
+			transform(context.Background(), compact)
+			transform(context.Background(), expanded)
+			if compact.Geometry == nil {
+				t.Fatalf(`%v/%v: expected geometry to be kept`, transformName, geometryName)
+			}
+			compact.ExpandCoordinates()
+			if !reflect.DeepEqual(compact, expanded) {
+				t.Fatalf(`%v/%v: expected %v, got %v`, transformName, geometryName, expanded, compact)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package geotiff builds GridValues from single-band GeoTIFF rasters,
+// including tiled, deflate-compressed Cloud Optimized GeoTIFFs.
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+)
+
+// GeoKeys read by this package.
+const (
+	keyModelType     = 1024
+	keyRasterType    = 1025
+	keyProjectedCRS  = 3072
+	modelProjected   = 1
+	modelGeographic  = 2
+	rasterPixelPoint = 2
+)
+
+// webMercatorCodes are the EPSG codes of the spherical Web Mercator CRS.
+var webMercatorCodes = map[uint64]bool{3857: true, 3785: true, 900913: true}
+
+// ErrUnsupportedCRS is returned for rasters whose coordinate reference system
+// isn't geographic or Web Mercator.
+var ErrUnsupportedCRS = errors.New("unsupported coordinate reference system")
+
+// Read decodes the full resolution image of a single-band GeoTIFF as a
+// GridValues ready for IsobandsFromGrid. Strips and tiles, no or deflate
+// compression, the horizontal and floating point predictors, and 8 to 64 bit
+// integer or float samples are supported, in classic or BigTIFF files.
+// Pixels equal to the GDAL_NODATA value are NaN.
+//
+// Coordinates are derived from the geotransform (ModelTiepoint and
+// ModelPixelScale, or ModelTransformation) at pixel centers. Geographic
+// rasters get a RegularLatLon geometry and Web Mercator (EPSG:3857) rasters a
+// ProjectedGrid; rotated or sheared transforms fall back to per-point Lats
+// and Lons. Rows are reordered to run south to north, matching the GRIB2
+// reader, so north-up images come back bottom row first.
+func Read(r io.ReaderAt) (*grid_to_isobands.GridValues, error) {
+	dir, err := readIFD(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading GeoTIFF: %w", err)
+	}
+	l, err := dir.layout()
+	if err != nil {
+		return nil, fmt.Errorf("error reading GeoTIFF image: %w", err)
+	}
+	values, err := l.decode(r, dir.order)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding GeoTIFF image: %w", err)
+	}
+	if err := applyNoData(values, dir.ascii(tagGDALNoData), l); err != nil {
+		return nil, fmt.Errorf("error reading GeoTIFF nodata: %w", err)
+	}
+
+	grid := &grid_to_isobands.GridValues{SizeX: l.width, SizeY: l.height, Values: values}
+	if err := georeference(grid, dir); err != nil {
+		return nil, fmt.Errorf("error georeferencing GeoTIFF: %w", err)
+	}
+	return grid, nil
+}
+
+// ReadFile opens path and reads the GeoTIFF in it.
+func ReadFile(path string) (*grid_to_isobands.GridValues, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening GeoTIFF: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// applyNoData replaces pixels equal to the GDAL_NODATA value with NaN. The
+// value is rounded to the sample type first, since GDAL writes it as text.
+func applyNoData(values []float64, text string, l *layout) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	nodata, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("invalid GDAL_NODATA %q: %w", text, err)
+	}
+	if l.format == sampleFloat && l.bits == 32 {
+		nodata = float64(float32(nodata))
+	}
+	for i, v := range values {
+		if v == nodata || (math.IsNaN(nodata) && math.IsNaN(v)) {
+			values[i] = math.NaN()
+		}
+	}
+	return nil
+}
+
+// geoKeys returns the GeoKeyDirectory's short-valued keys.
+func geoKeys(dir *ifd) map[uint64]uint64 {
+	keys := map[uint64]uint64{}
+	directory := dir.ints(tagGeoKeyDirectory)
+	if len(directory) < 4 {
+		return keys
+	}
+	for i := 0; i < int(directory[3]) && 4+i*4+3 < len(directory); i++ {
+		entry := directory[4+i*4:]
+		if entry[1] == 0 {
+			keys[entry[0]] = entry[3]
+		}
+	}
+	return keys
+}
+
+// georeference sets the grid's geometry, or per-point coordinates for
+// rotated rasters, and flips north-up images to run south to north.
+func georeference(grid *grid_to_isobands.GridValues, dir *ifd) error {
+	// x = a*col + b*row + c, y = d*col + e*row + f, at pixel corners.
+	var a, b, c, d, e, f float64
+	if m := dir.doubles(tagModelTransformation); len(m) == 16 {
+		a, b, c, d, e, f = m[0], m[1], m[3], m[4], m[5], m[7]
+	} else {
+		tie, scale := dir.doubles(tagModelTiepoint), dir.doubles(tagModelPixelScale)
+		if len(tie) < 6 || len(scale) < 2 {
+			return fmt.Errorf("missing ModelTiepoint/ModelPixelScale or ModelTransformation")
+		}
+		a, e = scale[0], -scale[1]
+		c, f = tie[3]-tie[0]*a, tie[4]-tie[1]*e
+	}
+
+	keys := geoKeys(dir)
+	// PixelIsArea transforms address pixel corners; move to pixel centers.
+	if keys[keyRasterType] != rasterPixelPoint {
+		c, f = c+(a+b)/2, f+(d+e)/2
+	}
+
+	var projection grid_to_isobands.Projection
+	switch keys[keyModelType] {
+	case modelGeographic, 0:
+	case modelProjected:
+		if !webMercatorCodes[keys[keyProjectedCRS]] {
+			return fmt.Errorf("projected CRS %d: %w", keys[keyProjectedCRS], ErrUnsupportedCRS)
+		}
+		projection = grid_to_isobands.Mercator{Radius: 6378137}
+	default:
+		return fmt.Errorf("model type %d: %w", keys[keyModelType], ErrUnsupportedCRS)
+	}
+
+	nx, ny := grid.SizeX, grid.SizeY
+	if b != 0 || d != 0 {
+		grid.Lats, grid.Lons = make([]float64, nx*ny), make([]float64, nx*ny)
+		for row := 0; row < ny; row++ {
+			for col := 0; col < nx; col++ {
+				x := a*float64(col) + b*float64(row) + c
+				y := d*float64(col) + e*float64(row) + f
+				i := row*nx + col
+				if projection == nil {
+					grid.Lats[i], grid.Lons[i] = y, x
+				} else {
+					grid.Lats[i], grid.Lons[i] = projection.Inverse(x, y)
+				}
+			}
+		}
+		return nil
+	}
+
+	if e < 0 {
+		flipRows(grid.Values, nx, ny)
+		f, e = f+e*float64(ny-1), -e
+	}
+	if projection == nil {
+		grid.Geometry = grid_to_isobands.RegularLatLon{Lat0: f, Lon0: c, DLat: e, DLon: a}
+	} else {
+		grid.Geometry = grid_to_isobands.ProjectedGrid{Projection: projection, X0: c, Y0: f, DX: a, DY: e}
+	}
+	return nil
+}
+
+func flipRows(values []float64, nx, ny int) {
+	for top, bottom := 0, ny-1; top < bottom; top, bottom = top+1, bottom-1 {
+		for x := 0; x < nx; x++ {
+			values[top*nx+x], values[bottom*nx+x] = values[bottom*nx+x], values[top*nx+x]
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package geotiff_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+	"github.com/skysparq/grid-to-isobands/geotiff"
+)
+
+type testTag struct {
+	tag       uint16
+	fieldType uint16
+	values    []float64
+	text      string
+}
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type testTIFF struct {
+	order     byteOrder
+	big       bool
+	width     int
+	height    int
+	tileSize  int // 0 writes one row per strip
+	float32s  bool
+	deflate   bool
+	predictor int
+	values    []float64
+	tags      []testTag
+}
+
+func (f testTIFF) sampleBytes() int {
+	if f.float32s {
+		return 4
+	}
+	return 2
+}
+
+// encodeRow writes samples in the file's byte order and applies the
+// predictor, the inverse of what the reader undoes.
+func (f testTIFF) encodeRow(samples []float64) []byte {
+	var row []byte
+	for _, v := range samples {
+		if f.float32s {
+			row = f.order.AppendUint32(row, math.Float32bits(float32(v)))
+		} else {
+			row = f.order.AppendUint16(row, uint16(int16(v)))
+		}
+	}
+	switch f.predictor {
+	case 2:
+		for i := len(samples) - 1; i > 0; i-- {
+			f.order.PutUint16(row[i*2:], f.order.Uint16(row[i*2:])-f.order.Uint16(row[(i-1)*2:]))
+		}
+	case 3:
+		n := len(samples)
+		planes := make([]byte, len(row))
+		for i, v := range samples {
+			be := binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(v)))
+			for b := range be {
+				planes[b*n+i] = be[b]
+			}
+		}
+		for i := len(planes) - 1; i > 0; i-- {
+			planes[i] -= planes[i-1]
+		}
+		row = planes
+	}
+	return row
+}
+
+func (f testTIFF) blocks() [][]byte {
+	var blocks [][]byte
+	blockW, blockH := f.width, 1
+	if f.tileSize > 0 {
+		blockW, blockH = f.tileSize, f.tileSize
+	}
+	for by := 0; by < f.height; by += blockH {
+		for bx := 0; bx < f.width; bx += blockW {
+			var block []byte
+			for y := by; y < by+blockH; y++ {
+				samples := make([]float64, blockW)
+				for x := range samples {
+					if y < f.height && bx+x < f.width {
+						samples[x] = f.values[y*f.width+bx+x]
+					}
+				}
+				block = append(block, f.encodeRow(samples)...)
+			}
+			if f.deflate {
+				var buf bytes.Buffer
+				w := zlib.NewWriter(&buf)
+				w.Write(block)
+				w.Close()
+				block = buf.Bytes()
+			}
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+func (f testTIFF) build() []byte {
+	o := f.order
+	out := []byte("II")
+	if o == binary.BigEndian {
+		out = []byte("MM")
+	}
+	headerSize := 8
+	if f.big {
+		out = o.AppendUint16(o.AppendUint16(o.AppendUint16(out, 43), 8), 0)
+		headerSize = 16
+	} else {
+		out = o.AppendUint16(out, 42)
+	}
+
+	blocks := f.blocks()
+	data := []byte{}
+	var offsets, counts []float64
+	for _, block := range blocks {
+		offsets = append(offsets, float64(headerSize+len(data)))
+		counts = append(counts, float64(len(block)))
+		data = append(data, block...)
+	}
+
+	format := 2.0
+	if f.float32s {
+		format = 3
+	}
+	compression := 1.0
+	if f.deflate {
+		compression = 8
+	}
+	tags := append([]testTag{
+		{tag: 256, fieldType: 4, values: []float64{float64(f.width)}},
+		{tag: 257, fieldType: 4, values: []float64{float64(f.height)}},
+		{tag: 258, fieldType: 3, values: []float64{float64(f.sampleBytes() * 8)}},
+		{tag: 259, fieldType: 3, values: []float64{compression}},
+		{tag: 277, fieldType: 3, values: []float64{1}},
+		{tag: 317, fieldType: 3, values: []float64{float64(max(f.predictor, 1))}},
+		{tag: 339, fieldType: 3, values: []float64{format}},
+	}, f.tags...)
+	if f.tileSize > 0 {
+		tags = append(tags,
+			testTag{tag: 322, fieldType: 4, values: []float64{float64(f.tileSize)}},
+			testTag{tag: 323, fieldType: 4, values: []float64{float64(f.tileSize)}},
+			testTag{tag: 324, fieldType: 4, values: offsets},
+			testTag{tag: 325, fieldType: 4, values: counts})
+	} else {
+		tags = append(tags,
+			testTag{tag: 273, fieldType: 4, values: offsets},
+			testTag{tag: 278, fieldType: 4, values: []float64{1}},
+			testTag{tag: 279, fieldType: 4, values: counts})
+	}
+	slices.SortFunc(tags, func(a, b testTag) int { return int(a.tag) - int(b.tag) })
+
+	countSize, entrySize, valueSize := 2, 12, 4
+	if f.big {
+		countSize, entrySize, valueSize = 8, 20, 8
+	}
+	ifdOffset := headerSize + len(data)
+	extra := ifdOffset + countSize + len(tags)*entrySize + valueSize
+	var entries, external []byte
+	for _, t := range tags {
+		var value []byte
+		switch t.fieldType {
+		case 2:
+			value = append([]byte(t.text), 0)
+		case 3:
+			for _, v := range t.values {
+				value = o.AppendUint16(value, uint16(v))
+			}
+		case 4:
+			for _, v := range t.values {
+				value = o.AppendUint32(value, uint32(v))
+			}
+		case 12:
+			for _, v := range t.values {
+				value = o.AppendUint64(value, math.Float64bits(v))
+			}
+		}
+		count := len(value)
+		if t.fieldType != 2 {
+			count = len(t.values)
+		}
+		entries = o.AppendUint16(o.AppendUint16(entries, t.tag), t.fieldType)
+		if f.big {
+			entries = o.AppendUint64(entries, uint64(count))
+		} else {
+			entries = o.AppendUint32(entries, uint32(count))
+		}
+		if len(value) <= valueSize {
+			entries = append(entries, append(value, make([]byte, valueSize-len(value))...)...)
+			continue
+		}
+		at := extra + len(external)
+		if f.big {
+			entries = o.AppendUint64(entries, uint64(at))
+		} else {
+			entries = o.AppendUint32(entries, uint32(at))
+		}
+		external = append(external, value...)
+	}
+
+	if f.big {
+		out = o.AppendUint64(out, uint64(ifdOffset))
+		out = append(out, data...)
+		out = o.AppendUint64(out, uint64(len(tags)))
+	} else {
+		out = o.AppendUint32(out, uint32(ifdOffset))
+		out = append(out, data...)
+		out = o.AppendUint16(out, uint16(len(tags)))
+	}
+	out = append(out, entries...)
+	out = append(out, make([]byte, valueSize)...) // no next IFD
+	return append(out, external...)
+}
+
+func geographicTags(pixelIsPoint bool) []testTag {
+	raster := 1.0
+	if pixelIsPoint {
+		raster = 2
+	}
+	return []testTag{
+		{tag: 33550, fieldType: 12, values: []float64{0.5, 0.25, 0}},
+		{tag: 33922, fieldType: 12, values: []float64{0, 0, 0, -100, 45, 0}},
+		{tag: 34735, fieldType: 3, values: []float64{1, 1, 0, 2, 1024, 0, 1, 2, 1025, 0, 1, raster}},
+	}
+}
+
+func TestReadTiledDeflateFloat(t *testing.T) {
+	values := []float64{
+		1, 2, 3,
+		4, -9999, 6,
+		7, 8, 9,
+	}
+	file := testTIFF{
+		order:     binary.LittleEndian,
+		width:     3,
+		height:    3,
+		tileSize:  2,
+		float32s:  true,
+		deflate:   true,
+		predictor: 3,
+		values:    values,
+		tags:      append(geographicTags(false), testTag{tag: 42113, fieldType: 2, text: `-9999`}),
+	}
+	grid, err := geotiff.Read(bytes.NewReader(file.build()))
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if grid.SizeX != 3 || grid.SizeY != 3 {
+		t.Fatalf(`expected 3x3 grid, got %vx%v`, grid.SizeX, grid.SizeY)
+	}
+	assertValues(t, grid.Values, []float64{7, 8, 9, 4, math.NaN(), 6, 1, 2, 3})
+	expected := grid_to_isobands.RegularLatLon{Lat0: 44.375, Lon0: -99.75, DLat: 0.25, DLon: 0.5}
+	if !reflect.DeepEqual(grid.Geometry, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, grid.Geometry)
+	}
+}
+
+func TestReadBigTIFFStrips(t *testing.T) {
+	file := testTIFF{
+		order:     binary.BigEndian,
+		big:       true,
+		width:     2,
+		height:    2,
+		predictor: 2,
+		values:    []float64{-5, 10, 300, -32768},
+		tags:      append(geographicTags(true), testTag{tag: 42113, fieldType: 2, text: `-32768`}),
+	}
+	grid, err := geotiff.Read(bytes.NewReader(file.build()))
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	assertValues(t, grid.Values, []float64{300, math.NaN(), -5, 10})
+	expected := grid_to_isobands.RegularLatLon{Lat0: 44.75, Lon0: -100, DLat: 0.25, DLon: 0.5}
+	if !reflect.DeepEqual(grid.Geometry, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, grid.Geometry)
+	}
+}
+
+func TestReadUnsupportedCRS(t *testing.T) {
+	file := testTIFF{
+		order:  binary.LittleEndian,
+		width:  1,
+		height: 1,
+		values: []float64{1},
+		tags: []testTag{
+			{tag: 33550, fieldType: 12, values: []float64{1000, 1000, 0}},
+			{tag: 33922, fieldType: 12, values: []float64{0, 0, 0, 500000, 4000000, 0}},
+			{tag: 34735, fieldType: 3, values: []float64{1, 1, 0, 2, 1024, 0, 1, 1, 3072, 0, 1, 32615}},
+		},
+	}
+	_, err := geotiff.Read(bytes.NewReader(file.build()))
+	if !errors.Is(err, geotiff.ErrUnsupportedCRS) {
+		t.Fatalf(`expected %v, got %v`, geotiff.ErrUnsupportedCRS, err)
+	}
+}
+
+func assertValues(t *testing.T, actual, expected []float64) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf(`expected %v values, got %v`, len(expected), len(actual))
+	}
+	for i := range expected {
+		if math.IsNaN(expected[i]) != math.IsNaN(actual[i]) || (!math.IsNaN(expected[i]) && expected[i] != actual[i]) {
+			t.Fatalf(`expected %v, got %v`, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package geotiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// TIFF tags read by this package.
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+
+	tagModelPixelScale     = 33550
+	tagModelTiepoint       = 33922
+	tagModelTransformation = 34264
+	tagGeoKeyDirectory     = 34735
+	tagGDALNoData          = 42113
+)
+
+// Compression schemes.
+const (
+	compressionNone          = 1
+	compressionDeflate       = 8
+	compressionDeflateLegacy = 32946
+)
+
+// Sample formats.
+const (
+	sampleUint  = 1
+	sampleInt   = 2
+	sampleFloat = 3
+)
+
+// field is a raw IFD entry; data holds count values of the entry's type in
+// the file's byte order.
+type field struct {
+	fieldType uint16
+	count     uint64
+	data      []byte
+}
+
+// ifd is the first image file directory of a TIFF, which holds the full
+// resolution image (later directories of a COG are overviews).
+type ifd struct {
+	order  binary.ByteOrder
+	fields map[uint16]field
+}
+
+var fieldSizes = map[uint16]uint64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 16: 8, 17: 8, 18: 8,
+}
+
+// readIFD parses the header of a classic or BigTIFF file and its first IFD.
+func readIFD(r io.ReaderAt) (*ifd, error) {
+	header := make([]byte, 16)
+	if _, err := r.ReadAt(header[:8], 0); err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+	var order binary.ByteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+
+	var offset uint64
+	big := false
+	switch order.Uint16(header[2:]) {
+	case 42:
+		offset = uint64(order.Uint32(header[4:]))
+	case 43:
+		big = true
+		if _, err := r.ReadAt(header, 0); err != nil {
+			return nil, fmt.Errorf("error reading BigTIFF header: %w", err)
+		}
+		if order.Uint16(header[4:]) != 8 {
+			return nil, fmt.Errorf("unsupported BigTIFF offset size %d", order.Uint16(header[4:]))
+		}
+		offset = order.Uint64(header[8:])
+	default:
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+
+	countSize, entrySize, valueSize := uint64(2), uint64(12), uint64(4)
+	if big {
+		countSize, entrySize, valueSize = 8, 20, 8
+	}
+	buf := make([]byte, countSize)
+	if _, err := r.ReadAt(buf, int64(offset)); err != nil {
+		return nil, fmt.Errorf("error reading IFD: %w", err)
+	}
+	count := uint64(order.Uint16(buf))
+	if big {
+		count = order.Uint64(buf)
+	}
+	if count > 1<<16 {
+		return nil, fmt.Errorf("invalid IFD entry count %d", count)
+	}
+	entries := make([]byte, count*entrySize)
+	if _, err := r.ReadAt(entries, int64(offset+countSize)); err != nil {
+		return nil, fmt.Errorf("error reading IFD entries: %w", err)
+	}
+
+	dir := &ifd{order: order, fields: map[uint16]field{}}
+	for i := uint64(0); i < count; i++ {
+		entry := entries[i*entrySize : (i+1)*entrySize]
+		f := field{fieldType: order.Uint16(entry[2:])}
+		var value []byte
+		if big {
+			f.count, value = order.Uint64(entry[4:]), entry[12:]
+		} else {
+			f.count, value = uint64(order.Uint32(entry[4:])), entry[8:]
+		}
+		size, ok := fieldSizes[f.fieldType]
+		if !ok {
+			continue // unknown types are skipped, as the TIFF spec requires
+		}
+		length := size * f.count
+		if length > 1<<30 {
+			return nil, fmt.Errorf("tag %d is too large", order.Uint16(entry))
+		}
+		if length <= valueSize {
+			f.data = value[:length]
+		} else {
+			at := uint64(order.Uint32(value))
+			if big {
+				at = order.Uint64(value)
+			}
+			f.data = make([]byte, length)
+			if _, err := r.ReadAt(f.data, int64(at)); err != nil {
+				return nil, fmt.Errorf("error reading tag %d: %w", order.Uint16(entry), err)
+			}
+		}
+		dir.fields[order.Uint16(entry)] = f
+	}
+	return dir, nil
+}
+
+// ints returns an integer tag's values.
+func (d *ifd) ints(tag uint16) []uint64 {
+	f, ok := d.fields[tag]
+	if !ok {
+		return nil
+	}
+	size := fieldSizes[f.fieldType]
+	values := make([]uint64, f.count)
+	for i := range values {
+		b := f.data[uint64(i)*size:]
+		switch f.fieldType {
+		case 1, 6, 7:
+			values[i] = uint64(b[0])
+		case 3, 8:
+			values[i] = uint64(d.order.Uint16(b))
+		case 4, 9:
+			values[i] = uint64(d.order.Uint32(b))
+		case 16, 17, 18:
+			values[i] = d.order.Uint64(b)
+		default:
+			return nil
+		}
+	}
+	return values
+}
+
+// int returns the first value of an integer tag, or fallback if it's absent.
+func (d *ifd) int(tag uint16, fallback uint64) uint64 {
+	if values := d.ints(tag); len(values) > 0 {
+		return values[0]
+	}
+	return fallback
+}
+
+// doubles returns a DOUBLE tag's values.
+func (d *ifd) doubles(tag uint16) []float64 {
+	f, ok := d.fields[tag]
+	if !ok || f.fieldType != 12 {
+		return nil
+	}
+	values := make([]float64, f.count)
+	for i := range values {
+		values[i] = math.Float64frombits(d.order.Uint64(f.data[i*8:]))
+	}
+	return values
+}
+
+// ascii returns an ASCII tag's value without its terminating NUL.
+func (d *ifd) ascii(tag uint16) string {
+	f, ok := d.fields[tag]
+	if !ok || f.fieldType != 2 {
+		return ""
+	}
+	return string(bytes.TrimRight(f.data, "\x00"))
+}
+
+// layout describes how the image is split into strips or tiles.
+type layout struct {
+	width, height         int
+	blockWidth, blockRows int
+	offsets, counts       []uint64
+	bits, format          int
+	compression           int
+	predictor             int
+}
+
+func (d *ifd) layout() (*layout, error) {
+	l := &layout{
+		width:       int(d.int(tagImageWidth, 0)),
+		height:      int(d.int(tagImageLength, 0)),
+		bits:        int(d.int(tagBitsPerSample, 1)),
+		format:      int(d.int(tagSampleFormat, sampleUint)),
+		compression: int(d.int(tagCompression, compressionNone)),
+		predictor:   int(d.int(tagPredictor, 1)),
+	}
+	if l.width <= 0 || l.height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", l.width, l.height)
+	}
+	if samples := d.int(tagSamplesPerPixel, 1); samples != 1 {
+		return nil, fmt.Errorf("expected a single band, got %d samples per pixel", samples)
+	}
+	for _, bits := range d.ints(tagBitsPerSample) {
+		if int(bits) != l.bits {
+			return nil, fmt.Errorf("mixed bits per sample are not supported")
+		}
+	}
+	switch {
+	case l.format == sampleFloat && (l.bits == 32 || l.bits == 64):
+	case (l.format == sampleUint || l.format == sampleInt) && (l.bits == 8 || l.bits == 16 || l.bits == 32 || l.bits == 64):
+	default:
+		return nil, fmt.Errorf("unsupported sample format %d with %d bits per sample", l.format, l.bits)
+	}
+	switch l.compression {
+	case compressionNone, compressionDeflate, compressionDeflateLegacy:
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", l.compression)
+	}
+	if l.predictor < 1 || l.predictor > 3 {
+		return nil, fmt.Errorf("unsupported predictor %d", l.predictor)
+	}
+
+	if _, tiled := d.fields[tagTileWidth]; tiled {
+		l.blockWidth = int(d.int(tagTileWidth, 0))
+		l.blockRows = int(d.int(tagTileLength, 0))
+		l.offsets, l.counts = d.ints(tagTileOffsets), d.ints(tagTileByteCounts)
+	} else {
+		l.blockWidth = l.width
+		l.blockRows = int(min(d.int(tagRowsPerStrip, uint64(l.height)), uint64(l.height)))
+		l.offsets, l.counts = d.ints(tagStripOffsets), d.ints(tagStripByteCounts)
+	}
+	if l.blockWidth <= 0 || l.blockRows <= 0 {
+		return nil, fmt.Errorf("invalid block size %dx%d", l.blockWidth, l.blockRows)
+	}
+	across := (l.width + l.blockWidth - 1) / l.blockWidth
+	down := (l.height + l.blockRows - 1) / l.blockRows
+	if len(l.offsets) != across*down || len(l.counts) != len(l.offsets) {
+		return nil, fmt.Errorf("expected %d blocks, got %d offsets and %d byte counts", across*down, len(l.offsets), len(l.counts))
+	}
+	return l, nil
+}
+
+// decode reads every strip or tile and returns the image as row-major
+// float64 values, top row first.
+func (l *layout) decode(r io.ReaderAt, order binary.ByteOrder) ([]float64, error) {
+	values := make([]float64, l.width*l.height)
+	across := (l.width + l.blockWidth - 1) / l.blockWidth
+	sampleBytes := l.bits / 8
+	rowBytes := l.blockWidth * sampleBytes
+
+	for i := range l.offsets {
+		bx, by := i%across*l.blockWidth, i/across*l.blockRows
+		rows := l.blockRows
+		if by+rows > l.height && l.blockWidth == l.width {
+			rows = l.height - by // the last strip may be short
+		}
+		block, err := l.readBlock(r, i, rows*rowBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading block %d: %w", i, err)
+		}
+		for row := 0; row < rows; row++ {
+			data := block[row*rowBytes : (row+1)*rowBytes]
+			if err := unpredict(data, l.predictor, sampleBytes, order); err != nil {
+				return nil, err
+			}
+			y := by + row
+			if y >= l.height {
+				break
+			}
+			for col := 0; col < l.blockWidth && bx+col < l.width; col++ {
+				values[y*l.width+bx+col] = l.sample(data[col*sampleBytes:], order)
+			}
+		}
+	}
+	return values, nil
+}
+
+func (l *layout) readBlock(r io.ReaderAt, i int, size int) ([]byte, error) {
+	raw := make([]byte, l.counts[i])
+	if _, err := r.ReadAt(raw, int64(l.offsets[i])); err != nil {
+		return nil, err
+	}
+	block := raw
+	if l.compression != compressionNone {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("error opening deflate stream: %w", err)
+		}
+		block = make([]byte, size)
+		if _, err := io.ReadFull(zr, block); err != nil {
+			return nil, fmt.Errorf("error inflating: %w", err)
+		}
+	}
+	if len(block) < size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(block))
+	}
+	return block, nil
+}
+
+func (l *layout) sample(b []byte, order binary.ByteOrder) float64 {
+	switch l.format<<8 | l.bits {
+	case sampleUint<<8 | 8:
+		return float64(b[0])
+	case sampleInt<<8 | 8:
+		return float64(int8(b[0]))
+	case sampleUint<<8 | 16:
+		return float64(order.Uint16(b))
+	case sampleInt<<8 | 16:
+		return float64(int16(order.Uint16(b)))
+	case sampleUint<<8 | 32:
+		return float64(order.Uint32(b))
+	case sampleInt<<8 | 32:
+		return float64(int32(order.Uint32(b)))
+	case sampleUint<<8 | 64:
+		return float64(order.Uint64(b))
+	case sampleInt<<8 | 64:
+		return float64(int64(order.Uint64(b)))
+	case sampleFloat<<8 | 32:
+		return float64(math.Float32frombits(order.Uint32(b)))
+	default:
+		return math.Float64frombits(order.Uint64(b))
+	}
+}
+
+// unpredict reverses a row's predictor in place. Horizontal differencing (2)
+// accumulates integer samples; the floating point predictor (3) accumulates
+// bytes and then reassembles each sample from its byte planes, which are
+// stored most significant first regardless of the file's byte order.
+func unpredict(row []byte, predictor, sampleBytes int, order binary.ByteOrder) error {
+	switch predictor {
+	case 1:
+	case 2:
+		n := len(row) / sampleBytes
+		for i := 1; i < n; i++ {
+			cur, prev := row[i*sampleBytes:], row[(i-1)*sampleBytes:]
+			switch sampleBytes {
+			case 1:
+				cur[0] += prev[0]
+			case 2:
+				order.PutUint16(cur, order.Uint16(cur)+order.Uint16(prev))
+			case 4:
+				order.PutUint32(cur, order.Uint32(cur)+order.Uint32(prev))
+			case 8:
+				order.PutUint64(cur, order.Uint64(cur)+order.Uint64(prev))
+			}
+		}
+	case 3:
+		for i := 1; i < len(row); i++ {
+			row[i] += row[i-1]
+		}
+		n := len(row) / sampleBytes
+		planes := bytes.Clone(row)
+		for i := 0; i < n; i++ {
+			for b := 0; b < sampleBytes; b++ {
+				// Plane b holds byte b of every sample, most significant first.
+				v := planes[b*n+i]
+				if order == binary.BigEndian {
+					row[i*sampleBytes+b] = v
+				} else {
+					row[i*sampleBytes+sampleBytes-1-b] = v
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported predictor %d", predictor)
+	}
+	return nil
+}
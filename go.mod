@@ -8,6 +8,7 @@ require (
 	github.com/paulmach/orb v0.13.0
 	github.com/skysparq/grib2-go v0.4.14
 	gonum.org/v1/gonum v0.17.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/x448/float16 v0.8.4 // indirect
@@ -0,0 +1,126 @@
+package grib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+)
+
+// Scanning mode flags (GRIB2 flag table 3.4).
+const (
+	scanNegativeI     = 0x80
+	scanPositiveJ     = 0x40
+	scanJConsecutive  = 0x20
+	scanBoustrophedon = 0x10
+)
+
+const (
+	microDegrees   = 1e-6
+	millimetersToM = 1e-3
+)
+
+// gridGeometry builds a compact geometry and the grid dimensions from the
+// grid definition section. Templates other than regular lat/lon (3.0),
+// Mercator (3.10), polar stereographic (3.20) and Lambert conformal (3.30)
+// return ok false so the caller can fall back to per-point coordinates.
+func gridGeometry(meta *Metadata) (geometry grid_to_isobands.GridGeometry, sizeX, sizeY int, ok bool, err error) {
+	s := meta.grid
+	need := map[int]int{0: 72, 10: 72, 20: 65, 30: 73}
+	size, known := need[meta.GridTemplate]
+	if !known {
+		return nil, 0, 0, false, nil
+	}
+	if len(s) < size {
+		return nil, 0, 0, false, fmt.Errorf("grid definition template 3.%d too short", meta.GridTemplate)
+	}
+	sizeX, sizeY = int(binary.BigEndian.Uint32(s[30:])), int(binary.BigEndian.Uint32(s[34:]))
+	if sizeX*sizeY != meta.NumPoints {
+		// Quasi-regular (reduced) grids list a varying number of points per row.
+		return nil, 0, 0, false, nil
+	}
+	radius := earthRadius(s)
+	// Along-row and along-column directions implied by the scanning mode, as
+	// stored in the message (before any reordering).
+	iSign, jSign := 1.0, -1.0
+	if meta.ScanMode&scanNegativeI != 0 {
+		iSign = -1
+	}
+	if meta.ScanMode&scanPositiveJ != 0 {
+		jSign = 1
+	}
+
+	switch meta.GridTemplate {
+	case 0:
+		unit := microDegrees
+		basicAngle, subdivisions := binary.BigEndian.Uint32(s[38:]), binary.BigEndian.Uint32(s[42:])
+		if basicAngle != 0 && basicAngle != math.MaxUint32 && subdivisions != 0 && subdivisions != math.MaxUint32 {
+			unit = float64(basicAngle) / float64(subdivisions)
+		}
+		la1, lo1 := angle(s[46:], unit), angle(s[50:], unit)
+		la2, lo2 := angle(s[55:], unit), angle(s[59:], unit)
+		di, dj := float64(binary.BigEndian.Uint32(s[63:]))*unit, float64(binary.BigEndian.Uint32(s[67:]))*unit
+		if allOnes(s[63:67]) && sizeX > 1 {
+			di = math.Abs(eastwardSpan(lo1, lo2, iSign)) / float64(sizeX-1)
+		}
+		if allOnes(s[67:71]) && sizeY > 1 {
+			dj = math.Abs(la2-la1) / float64(sizeY-1)
+		}
+		geometry = grid_to_isobands.RegularLatLon{Lat0: la1, Lon0: lo1, DLat: jSign * dj, DLon: iSign * di}
+	case 10:
+		la1, lo1 := angle(s[38:], microDegrees), angle(s[42:], microDegrees)
+		lad := angle(s[47:], microDegrees)
+		di, dj := float64(binary.BigEndian.Uint32(s[64:]))*millimetersToM, float64(binary.BigEndian.Uint32(s[68:]))*millimetersToM
+		projection := grid_to_isobands.Mercator{LatTs: lad, Lon0: lo1, Radius: radius}
+		geometry = grid_to_isobands.NewProjectedGrid(projection, la1, lo1, iSign*di, jSign*dj)
+	case 20:
+		la1, lo1 := angle(s[38:], microDegrees), angle(s[42:], microDegrees)
+		lad, lov := angle(s[47:], microDegrees), angle(s[51:], microDegrees)
+		dx, dy := float64(binary.BigEndian.Uint32(s[55:]))*millimetersToM, float64(binary.BigEndian.Uint32(s[59:]))*millimetersToM
+		projection := grid_to_isobands.PolarStereographic{LatTs: lad, Lon0: lov, South: s[63]&0x80 != 0, Radius: radius}
+		geometry = grid_to_isobands.NewProjectedGrid(projection, la1, lo1, iSign*dx, jSign*dy)
+	case 30:
+		la1, lo1 := angle(s[38:], microDegrees), angle(s[42:], microDegrees)
+		lad, lov := angle(s[47:], microDegrees), angle(s[51:], microDegrees)
+		dx, dy := float64(binary.BigEndian.Uint32(s[55:]))*millimetersToM, float64(binary.BigEndian.Uint32(s[59:]))*millimetersToM
+		latin1, latin2 := angle(s[65:], microDegrees), angle(s[69:], microDegrees)
+		projection := grid_to_isobands.LambertConformal{Lat1: latin1, Lat2: latin2, Lat0: lad, Lon0: lov, Radius: radius}
+		geometry = grid_to_isobands.NewProjectedGrid(projection, la1, lo1, iSign*dx, jSign*dy)
+	}
+	return geometry, sizeX, sizeY, true, nil
+}
+
+// eastwardSpan returns the longitude distance from lo1 to lo2 travelling in
+// the scanning direction, so grids that cross the prime meridian or
+// antimeridian don't produce a negative span.
+func eastwardSpan(lo1, lo2, iSign float64) float64 {
+	span := math.Mod((lo2-lo1)*iSign, 360)
+	if span < 0 {
+		span += 360
+	}
+	return span
+}
+
+func angle(b []byte, unit float64) float64 {
+	return float64(signMagnitude32(b)) * unit
+}
+
+// earthRadius returns the spherical radius described by the shape of the
+// earth octets shared by the grid templates. Oblate shapes use the WGS84
+// mean radius, since the projections here are spherical.
+func earthRadius(s []byte) float64 {
+	switch s[14] {
+	case 0:
+		return 6367470
+	case 1:
+		scale := signMagnitude8(s[15])
+		return float64(binary.BigEndian.Uint32(s[16:])) * math.Pow(10, float64(-scale))
+	case 6:
+		return 6371229
+	case 8:
+		return 6371200
+	default:
+		return 6371008.8
+	}
+}
@@ -0,0 +1,338 @@
+package grib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Metadata describes a GRIB2 message, read directly from its indicator,
+// identification, grid definition and product definition sections.
+type Metadata struct {
+	MessageNumber   int
+	Discipline      int
+	Center          int
+	SubCenter       int
+	ReferenceTime   time.Time
+	GridTemplate    int
+	ProductTemplate int
+	Category        int
+	Parameter       int
+	// ForecastTime is the forecast offset in ForecastUnit (GRIB2 code table
+	// 4.4), or -1 when the product template has none.
+	ForecastTime int
+	ForecastUnit int
+	// LevelType is the type of the first fixed surface (code table 4.5) and
+	// LevelValue its value, NaN when missing.
+	LevelType  int
+	LevelValue float64
+	NumPoints  int
+	// ScanMode holds the grid's scanning mode flags (flag table 3.4).
+	ScanMode byte
+
+	grid    []byte
+	bitmap  []byte
+	dataRep []byte
+}
+
+// Props returns the metadata as properties for IsobandArgs.AddlProps.
+func (m *Metadata) Props() map[string]any {
+	props := map[string]any{
+		`discipline`:        m.Discipline,
+		`parameterCategory`: m.Category,
+		`parameterNumber`:   m.Parameter,
+		`center`:            m.Center,
+		`referenceTime`:     m.ReferenceTime,
+	}
+	if m.LevelType != 255 {
+		props[`levelType`] = m.LevelType
+		if !math.IsNaN(m.LevelValue) {
+			props[`levelValue`] = m.LevelValue
+		}
+	}
+	if m.ForecastTime >= 0 {
+		props[`forecastTime`] = m.ForecastTime
+		props[`forecastUnit`] = m.ForecastUnit
+		if valid, ok := m.ValidTime(); ok {
+			props[`validTime`] = valid
+		}
+	}
+	return props
+}
+
+// ValidTime returns ReferenceTime plus the forecast offset, when the forecast
+// unit is one that maps to a fixed duration.
+func (m *Metadata) ValidTime() (time.Time, bool) {
+	units := map[int]time.Duration{
+		0:  time.Minute,
+		1:  time.Hour,
+		2:  24 * time.Hour,
+		10: 3 * time.Hour,
+		11: 6 * time.Hour,
+		12: 12 * time.Hour,
+		13: time.Second,
+	}
+	unit, ok := units[m.ForecastUnit]
+	if !ok || m.ForecastTime < 0 {
+		return time.Time{}, false
+	}
+	return m.ReferenceTime.Add(time.Duration(m.ForecastTime) * unit), true
+}
+
+// Selector picks which message to read from a GRIB2 stream.
+type Selector func(meta *Metadata) bool
+
+// MessageNumber selects the n-th message in the stream, counting from 1.
+func MessageNumber(n int) Selector {
+	return func(meta *Metadata) bool { return meta.MessageNumber == n }
+}
+
+// Parameter selects messages for the given discipline, parameter category
+// and parameter number (GRIB2 code tables 0.0 and 4.2).
+func Parameter(discipline, category, parameter int) Selector {
+	return func(meta *Metadata) bool {
+		return meta.Discipline == discipline && meta.Category == category && meta.Parameter == parameter
+	}
+}
+
+// Level selects messages whose first fixed surface has the given type (code
+// table 4.5) and value, e.g. Level(100, 50000) for the 500 hPa isobaric
+// surface.
+func Level(levelType int, value float64) Selector {
+	return func(meta *Metadata) bool {
+		return meta.LevelType == levelType && meta.LevelValue == value
+	}
+}
+
+// All selects messages matched by every one of selectors.
+func All(selectors ...Selector) Selector {
+	return func(meta *Metadata) bool {
+		for _, selector := range selectors {
+			if !selector(meta) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ErrNotFound is returned when no message in the stream matches the selector.
+var ErrNotFound = errors.New("no matching GRIB2 message")
+
+// findMessage scans r for the first message matching selector and returns
+// its raw bytes along with its parsed metadata. A message whose bitmap
+// indicator is 254 takes the bitmap most recently defined earlier in the
+// stream.
+func findMessage(r io.Reader, selector Selector) ([]byte, *Metadata, error) {
+	br := bufio.NewReader(r)
+	var bitmap []byte
+	for number := 1; ; number++ {
+		message, err := nextMessage(br)
+		if err == io.EOF {
+			return nil, nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading message %d: %w", number, err)
+		}
+		meta, err := parseMessage(message)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing message %d: %w", number, err)
+		}
+		meta.MessageNumber = number
+		if len(meta.bitmap) >= 6 {
+			switch meta.bitmap[5] {
+			case 0:
+				bitmap = meta.bitmap
+			case 254:
+				if bitmap == nil {
+					return nil, nil, fmt.Errorf("message %d reuses a previously defined bitmap, but no earlier message defines one", number)
+				}
+				meta.bitmap = bitmap
+			}
+		}
+		if selector(meta) {
+			return message, meta, nil
+		}
+	}
+}
+
+// nextMessage reads the next complete GRIB2 message, skipping any padding
+// between messages.
+func nextMessage(br *bufio.Reader) ([]byte, error) {
+	if err := skipToMagic(br); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("truncated indicator section: %w", err)
+	}
+	if header[7] != 2 {
+		return nil, fmt.Errorf("unsupported GRIB edition %d", header[7])
+	}
+	length := binary.BigEndian.Uint64(header[8:16])
+	if length < 16+4 || length > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid message length %d", length)
+	}
+	message := make([]byte, length)
+	copy(message, header)
+	if _, err := io.ReadFull(br, message[16:]); err != nil {
+		return nil, fmt.Errorf("truncated message: %w", err)
+	}
+	if !bytes.Equal(message[length-4:], []byte("7777")) {
+		return nil, fmt.Errorf("message does not end with 7777")
+	}
+	return message, nil
+}
+
+func skipToMagic(br *bufio.Reader) error {
+	for {
+		peek, err := br.Peek(4)
+		if err != nil {
+			if len(peek) == 0 || err == io.EOF {
+				return io.EOF
+			}
+			return err
+		}
+		if string(peek) == "GRIB" {
+			return nil
+		}
+		if _, err := br.Discard(1); err != nil {
+			return err
+		}
+	}
+}
+
+// parseMessage reads the sections of a single-field message.
+func parseMessage(message []byte) (*Metadata, error) {
+	meta := &Metadata{Discipline: int(message[6]), ForecastTime: -1, LevelType: 255, LevelValue: math.NaN()}
+	offset := 16
+	for offset+4 <= len(message) {
+		if string(message[offset:offset+4]) == "7777" {
+			break
+		}
+		if offset+5 > len(message) {
+			return nil, fmt.Errorf("truncated section at offset %d", offset)
+		}
+		length := int(binary.BigEndian.Uint32(message[offset:]))
+		if length < 5 || offset+length > len(message) {
+			return nil, fmt.Errorf("invalid section length %d at offset %d", length, offset)
+		}
+		section := message[offset : offset+length]
+		switch section[4] {
+		case 1:
+			if err := parseIdentification(meta, section); err != nil {
+				return nil, err
+			}
+		case 3:
+			if meta.grid != nil {
+				return meta, nil // multi-field message: only the first field is read
+			}
+			if err := parseGridDefinition(meta, section); err != nil {
+				return nil, err
+			}
+		case 4:
+			if err := parseProductDefinition(meta, section); err != nil {
+				return nil, err
+			}
+		case 5:
+			meta.dataRep = section
+		case 6:
+			meta.bitmap = section
+		}
+		offset += length
+	}
+	if meta.grid == nil {
+		return nil, fmt.Errorf("message has no grid definition section")
+	}
+	return meta, nil
+}
+
+func parseIdentification(meta *Metadata, section []byte) error {
+	if len(section) < 21 {
+		return fmt.Errorf("identification section too short")
+	}
+	meta.Center = int(binary.BigEndian.Uint16(section[5:]))
+	meta.SubCenter = int(binary.BigEndian.Uint16(section[7:]))
+	meta.ReferenceTime = time.Date(
+		int(binary.BigEndian.Uint16(section[12:])), time.Month(section[14]), int(section[15]),
+		int(section[16]), int(section[17]), int(section[18]), 0, time.UTC)
+	return nil
+}
+
+func parseGridDefinition(meta *Metadata, section []byte) error {
+	if len(section) < 14 {
+		return fmt.Errorf("grid definition section too short")
+	}
+	meta.NumPoints = int(binary.BigEndian.Uint32(section[6:]))
+	meta.GridTemplate = int(binary.BigEndian.Uint16(section[12:]))
+	meta.grid = section
+	if offset, ok := scanModeOffsets[meta.GridTemplate]; ok && offset < len(section) {
+		meta.ScanMode = section[offset]
+	}
+	return nil
+}
+
+// scanModeOffsets maps grid definition templates to the 0-based offset of the
+// scanning mode octet within section 3.
+var scanModeOffsets = map[int]int{
+	0:  71,
+	10: 59,
+	20: 64,
+	30: 64,
+	40: 71,
+}
+
+func parseProductDefinition(meta *Metadata, section []byte) error {
+	if len(section) < 11 {
+		return fmt.Errorf("product definition section too short")
+	}
+	meta.ProductTemplate = int(binary.BigEndian.Uint16(section[7:]))
+	meta.Category = int(section[9])
+	meta.Parameter = int(section[10])
+	// Templates 4.0 through 4.15 share the layout of the time and fixed
+	// surface octets used here.
+	if meta.ProductTemplate > 15 || len(section) < 28 {
+		return nil
+	}
+	meta.ForecastUnit = int(section[17])
+	meta.ForecastTime = int(signMagnitude32(section[18:]))
+	meta.LevelType = int(section[22])
+	if section[23] != 0xff && !allOnes(section[24:28]) {
+		scale := int(signMagnitude8(section[23]))
+		meta.LevelValue = float64(signMagnitude32(section[24:])) * math.Pow(10, float64(-scale))
+	}
+	return nil
+}
+
+// signMagnitude32 decodes a GRIB2 signed integer, which stores the sign in
+// the most significant bit rather than as two's complement.
+func signMagnitude32(b []byte) int32 {
+	v := binary.BigEndian.Uint32(b)
+	magnitude := int32(v & 0x7fffffff)
+	if v&0x80000000 != 0 {
+		return -magnitude
+	}
+	return magnitude
+}
+
+func signMagnitude8(b byte) int8 {
+	magnitude := int8(b & 0x7f)
+	if b&0x80 != 0 {
+		return -magnitude
+	}
+	return magnitude
+}
+
+func allOnes(b []byte) bool {
+	for _, v := range b {
+		if v != 0xff {
+			return false
+		}
+	}
+	return true
+}
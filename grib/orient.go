@@ -0,0 +1,145 @@
+package grib
+
+import (
+	"fmt"
+	"math"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+)
+
+// orient reorders a grid decoded in GRIB2 storage order into the canonical
+// orientation used by IsobandsFromGrid: row-major, rows running south to
+// north, columns west to east, and longitudes in -180..180. Global regular
+// lat/lon grids have their columns rotated to start at -180, which replaces
+// the SwapRightAndLeftTransformer/ReverseVerticalTransformer steps GFS and
+// MRMS products otherwise need.
+func orient(grid *grid_to_isobands.GridValues, meta *Metadata) error {
+	nx, ny := grid.SizeX, grid.SizeY
+	if len(grid.Values) != nx*ny {
+		return fmt.Errorf("decoded %d values for a %dx%d grid", len(grid.Values), nx, ny)
+	}
+	flipI := meta.ScanMode&scanNegativeI != 0
+	flipJ := meta.ScanMode&scanPositiveJ == 0
+
+	// source maps a canonical (x, y) to its index in storage order.
+	source := func(x, y int) int {
+		i, j := x, y
+		if flipI {
+			i = nx - 1 - x
+		}
+		if flipJ {
+			j = ny - 1 - y
+		}
+		if meta.ScanMode&scanJConsecutive != 0 {
+			if meta.ScanMode&scanBoustrophedon != 0 && i%2 == 1 {
+				j = ny - 1 - j
+			}
+			return i*ny + j
+		}
+		if meta.ScanMode&scanBoustrophedon != 0 && j%2 == 1 {
+			i = nx - 1 - i
+		}
+		return j*nx + i
+	}
+	reorder := func(data []float64) []float64 {
+		out := make([]float64, len(data))
+		for y := 0; y < ny; y++ {
+			for x := 0; x < nx; x++ {
+				out[y*nx+x] = data[source(x, y)]
+			}
+		}
+		return out
+	}
+
+	if meta.ScanMode&(scanNegativeI|scanJConsecutive|scanBoustrophedon) != 0 || flipJ {
+		grid.Values = reorder(grid.Values)
+		if grid.Geometry == nil {
+			grid.Lats, grid.Lons = reorder(grid.Lats), reorder(grid.Lons)
+		}
+	}
+
+	i0, j0 := 0, 0
+	if flipI {
+		i0 = nx - 1
+	}
+	if flipJ {
+		j0 = ny - 1
+	}
+	switch g := grid.Geometry.(type) {
+	case grid_to_isobands.RegularLatLon:
+		g.Lat0, g.Lon0 = g.LatLon(i0, j0)
+		g.DLat, g.DLon = math.Abs(g.DLat), math.Abs(g.DLon)
+		grid.Geometry = normalizeLongitudes(grid, g)
+	case grid_to_isobands.ProjectedGrid:
+		g.X0, g.Y0 = g.XY(i0, j0)
+		g.DX, g.DY = math.Abs(g.DX), math.Abs(g.DY)
+		grid.Geometry = g
+	case nil:
+		normalizePointLongitudes(grid.Lons)
+	}
+	return nil
+}
+
+// normalizeLongitudes moves a west-to-east regular grid into -180..180. Grids
+// that wrap the whole globe have their columns rotated so the first column is
+// the westernmost one at or after -180; regional grids are only relabelled,
+// and may run past 180 if they cross the antimeridian.
+func normalizeLongitudes(grid *grid_to_isobands.GridValues, g grid_to_isobands.RegularLatLon) grid_to_isobands.RegularLatLon {
+	nx := grid.SizeX
+	// Global grids cover 360 degrees to within half a column.
+	global := math.Abs(float64(nx)*g.DLon-360) < g.DLon/2
+	if !global || g.DLon == 0 {
+		g.Lon0 = wrapLon(g.Lon0)
+		return g
+	}
+
+	// The first column whose wrapped longitude is smallest becomes column 0.
+	shift, westmost := 0, math.Inf(1)
+	for x := 0; x < nx; x++ {
+		_, lon := g.LatLon(x, 0)
+		if wrapped := wrapLon(lon); wrapped < westmost {
+			shift, westmost = x, wrapped
+		}
+	}
+	if shift != 0 {
+		rotated := make([]float64, len(grid.Values))
+		for y := 0; y < grid.SizeY; y++ {
+			row := grid.Values[y*nx : (y+1)*nx]
+			copy(rotated[y*nx:], row[shift:])
+			copy(rotated[y*nx+nx-shift:], row[:shift])
+		}
+		grid.Values = rotated
+	}
+	g.Lon0 = westmost
+	return g
+}
+
+// normalizePointLongitudes wraps per-point longitudes into -180..180 unless
+// doing so would split the grid across the antimeridian.
+func normalizePointLongitudes(lons []float64) {
+	if len(lons) == 0 {
+		return
+	}
+	rawMin, rawMax := math.Inf(1), math.Inf(-1)
+	wrappedMin, wrappedMax := math.Inf(1), math.Inf(-1)
+	for _, lon := range lons {
+		rawMin, rawMax = min(rawMin, lon), max(rawMax, lon)
+		wrapped := wrapLon(lon)
+		wrappedMin, wrappedMax = min(wrappedMin, wrapped), max(wrappedMax, wrapped)
+	}
+	if wrappedMax-wrappedMin > rawMax-rawMin {
+		return
+	}
+	for i, lon := range lons {
+		lons[i] = wrapLon(lon)
+	}
+}
+
+// wrapLon wraps a longitude into [-180, 180).
+func wrapLon(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
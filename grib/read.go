@@ -0,0 +1,154 @@
+// Package grib builds GridValues directly from GRIB2 messages.
+package grib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/skysparq/grib2-go/file"
+	"github.com/skysparq/grib2-go/templates"
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+)
+
+// Read scans a GRIB2 stream for the first message matched by selector and
+// returns its values as a GridValues ready for IsobandsFromGrid, along with
+// the message metadata (see Metadata.Props for AddlProps). Regular lat/lon
+// and projected grids are described by a compact Geometry; other grid
+// templates fall back to per-point Lats and Lons. Points masked out by the
+// bitmap or flagged with a missing value substitute are NaN.
+//
+// The grid's scanning mode and longitude convention are normalized, so the
+// result always runs west to east and south to north with longitudes in
+// -180..180 (see orient), whatever order the message stores its points in.
+func Read(r io.Reader, selector Selector) (*grid_to_isobands.GridValues, *Metadata, error) {
+	message, meta, err := findMessage(r, selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading GRIB2 message: %w", err)
+	}
+
+	geometry, sizeX, sizeY, ok, err := gridGeometry(meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading GRIB2 grid definition: %w", err)
+	}
+
+	grid := &grid_to_isobands.GridValues{SizeX: sizeX, SizeY: sizeY, Geometry: geometry}
+	gribFile := file.NewGribFile(bytes.NewReader(message), templates.Version33())
+	for indexed, err := range gribFile.Records {
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read grib record: %w", err)
+		}
+		dataRep, err := indexed.Record.DataRepresentation.Definition()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read data representation: %w", err)
+		}
+		grid.Values, err = dataRep.GetValues(indexed.Record)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read values: %w", err)
+		}
+		if !ok {
+			gridDef, err := indexed.Record.Grid.Definition()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read grid definition: %w", err)
+			}
+			points, err := gridDef.Points()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read grid points: %w", err)
+			}
+			grid.SizeX, grid.SizeY = gridDef.XVals(), gridDef.YVals()
+			grid.Lats, grid.Lons = points.Lats, points.Lngs
+		}
+		break
+	}
+	if grid.Values == nil {
+		return nil, nil, fmt.Errorf("error reading GRIB2 message %d: no records decoded", meta.MessageNumber)
+	}
+
+	grid.Values, err = applyBitmap(grid.Values, meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error applying GRIB2 bitmap: %w", err)
+	}
+	maskMissingValues(grid.Values, meta)
+	err = orient(grid, meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error orienting GRIB2 grid: %w", err)
+	}
+	return grid, meta, nil
+}
+
+// applyBitmap sets points the bitmap marks as absent to NaN, expanding the
+// values first if only the present points were decoded. Bitmaps predefined
+// by the originating centre (indicators 1 to 253) aren't supported.
+func applyBitmap(values []float64, meta *Metadata) ([]float64, error) {
+	if len(meta.bitmap) < 6 || meta.bitmap[5] == 255 {
+		return values, nil
+	}
+	if meta.bitmap[5] != 0 {
+		return nil, fmt.Errorf("predefined bitmap %d is not supported", meta.bitmap[5])
+	}
+	bits := meta.bitmap[6:]
+	if len(bits)*8 < meta.NumPoints {
+		return nil, fmt.Errorf("bitmap has %d bits for %d points", len(bits)*8, meta.NumPoints)
+	}
+	present := func(i int) bool { return bits[i/8]&(0x80>>(i%8)) != 0 }
+
+	if len(values) == meta.NumPoints {
+		for i := range values {
+			if !present(i) {
+				values[i] = math.NaN()
+			}
+		}
+		return values, nil
+	}
+	expanded := make([]float64, meta.NumPoints)
+	next := 0
+	for i := range expanded {
+		if !present(i) {
+			expanded[i] = math.NaN()
+			continue
+		}
+		if next >= len(values) {
+			return nil, fmt.Errorf("bitmap marks more points present than the %d values decoded", len(values))
+		}
+		expanded[i] = values[next]
+		next++
+	}
+	return expanded, nil
+}
+
+// maskMissingValues replaces the primary and secondary missing value
+// substitutes of complex packing (data representation templates 5.2 and 5.3)
+// with NaN.
+func maskMissingValues(values []float64, meta *Metadata) {
+	s := meta.dataRep
+	if len(s) < 31 {
+		return
+	}
+	template := binary.BigEndian.Uint16(s[9:])
+	if template != 2 && template != 3 {
+		return
+	}
+	management := s[22]
+	if management == 0 {
+		return
+	}
+	substitute := func(b []byte) float64 {
+		if s[20] == 0 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		}
+		return float64(signMagnitude32(b))
+	}
+	missing := []float64{substitute(s[23:27])}
+	if management == 2 {
+		missing = append(missing, substitute(s[27:31]))
+	}
+	for i, v := range values {
+		for _, m := range missing {
+			if v == m {
+				values[i] = math.NaN()
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package grib_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+	"github.com/skysparq/grid-to-isobands/grib"
+)
+
+type testMessage struct {
+	category, parameter int
+	scanMode            byte
+	values              []uint16
+	// present marks which grid points have a value; nil means all of them.
+	present []bool
+	// reuseBitmap writes bitmap indicator 254 in place of present's bits,
+	// while still packing only the present values.
+	reuseBitmap bool
+	// firstLon and lonStep override the default 260 degrees east first
+	// column and 1 degree column spacing.
+	firstLon, lonStep uint32
+}
+
+// build assembles a regular lat/lon GRIB2 message with len(values)/2 columns
+// and 2 rows (50N and 49N, starting at 100W), using 16-bit simple packing
+// with a reference value of 100.
+func (m testMessage) build() []byte {
+	be := binary.BigEndian
+	section := func(number byte, body ...[]byte) []byte {
+		content := bytes.Join(body, nil)
+		out := be.AppendUint32(nil, uint32(len(content)+5))
+		return append(append(out, number), content...)
+	}
+	u8 := func(v byte) []byte { return []byte{v} }
+	u16 := func(v uint16) []byte { return be.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return be.AppendUint32(nil, v) }
+
+	identification := section(1, u16(7), u16(0), u8(2), u8(1), u8(1),
+		u16(2025), u8(10), u8(2), u8(12), u8(0), u8(0), u8(0), u8(1))
+	nx := uint32(len(m.values) / 2)
+	lonStep := m.lonStep
+	if lonStep == 0 {
+		lonStep = 1e6
+	}
+	la1, la2 := uint32(50e6), uint32(49e6)
+	if m.scanMode&0x40 != 0 {
+		la1, la2 = la2, la1
+	}
+	lo1 := uint32(260e6)
+	if m.firstLon != 0 {
+		lo1 = m.firstLon
+	}
+	lo2 := lo1 + (nx-1)*lonStep
+	if m.scanMode&0x80 != 0 {
+		lo1, lo2 = lo2, lo1
+	}
+	grid := section(3, u8(0), u32(nx*2), u8(0), u8(0), u16(0),
+		u8(6), u8(0), u32(0), u8(0), u32(0), u8(0), u32(0),
+		u32(nx), u32(2), u32(0), u32(math.MaxUint32),
+		u32(la1), u32(lo1), u8(0x30), u32(la2), u32(lo2), u32(lonStep), u32(1e6), u8(m.scanMode))
+	product := section(4, u16(0), u16(0), u8(byte(m.category)), u8(byte(m.parameter)), u8(2), u8(0), u8(0),
+		u16(0), u8(0), u8(1), u32(6), u8(103), u8(0), u32(2), u8(255), u8(255), u32(math.MaxUint32))
+
+	bitmap := section(6, u8(255))
+	packed := make([]byte, 0, len(m.values)*2)
+	count := 0
+	if m.present != nil {
+		bits := make([]byte, (len(m.present)+7)/8)
+		for i, present := range m.present {
+			if present {
+				bits[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+		bitmap = section(6, u8(0), bits)
+		if m.reuseBitmap {
+			bitmap = section(6, u8(254))
+		}
+	}
+	for i, v := range m.values {
+		if m.present != nil && !m.present[i] {
+			continue
+		}
+		packed = append(packed, u16(v)...)
+		count++
+	}
+	dataRep := section(5, u32(uint32(count)), u16(0), u32(math.Float32bits(100)), u16(0), u16(0), u8(16), u8(0))
+	data := section(7, packed)
+
+	body := bytes.Join([][]byte{identification, grid, product, dataRep, bitmap, data, []byte("7777")}, nil)
+	header := append([]byte("GRIB"), 0, 0, 0, 2)
+	header = be.AppendUint64(header, uint64(len(body)+16))
+	return append(header, body...)
+}
+
+func TestReadSelectsByParameter(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		testMessage{category: 0, parameter: 0, values: []uint16{0, 0, 0, 0, 0, 0}}.build(),
+		make([]byte, 4), // padding between messages
+		testMessage{
+			category:  1,
+			parameter: 8,
+			values:    []uint16{0, 1, 2, 3, 0, 5},
+			present:   []bool{true, true, true, true, false, true},
+		}.build(),
+	}, nil)
+
+	grid, meta, err := grib.Read(bytes.NewReader(stream), grib.Parameter(0, 1, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.MessageNumber != 2 {
+		t.Fatalf(`expected message 2, got %v`, meta.MessageNumber)
+	}
+	// Stored north to south, read back south to north.
+	expectedGeometry := grid_to_isobands.RegularLatLon{Lat0: 49, Lon0: -100, DLat: 1, DLon: 1}
+	if grid.SizeX != 3 || grid.SizeY != 2 || !reflect.DeepEqual(grid.Geometry, expectedGeometry) {
+		t.Fatalf(`expected 3x2 grid with geometry %v, got %vx%v with %v`, expectedGeometry, grid.SizeX, grid.SizeY, grid.Geometry)
+	}
+	assertValues(t, grid.Values, []float64{103, math.NaN(), 105, 100, 101, 102})
+
+	props := meta.Props()
+	if props[`levelType`] != 103 || props[`levelValue`] != 2.0 {
+		t.Fatalf(`expected level 103/2, got %v`, props)
+	}
+	if props[`validTime`] != time.Date(2025, 10, 2, 18, 0, 0, 0, time.UTC) {
+		t.Fatalf(`expected valid time 2025-10-02T18:00Z, got %v`, props[`validTime`])
+	}
+}
+
+func TestReadReusesPreviousBitmap(t *testing.T) {
+	present := []bool{true, false, true, true, true, false}
+	stream := bytes.Join([][]byte{
+		testMessage{values: []uint16{0, 0, 0, 0, 0, 0}, present: present}.build(),
+		testMessage{parameter: 1, values: []uint16{0, 0, 2, 3, 4, 0}, present: present, reuseBitmap: true}.build(),
+	}, nil)
+
+	grid, _, err := grib.Read(bytes.NewReader(stream), grib.MessageNumber(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, grid.Values, []float64{103, 104, math.NaN(), 100, math.NaN(), 102})
+}
+
+func TestReadRejectsReusedBitmapWithoutPrevious(t *testing.T) {
+	stream := testMessage{
+		values:      []uint16{0, 1, 2, 3, 4, 5},
+		present:     []bool{true, true, true, true, true, false},
+		reuseBitmap: true,
+	}.build()
+	_, _, err := grib.Read(bytes.NewReader(stream), grib.MessageNumber(1))
+	if err == nil {
+		t.Fatalf(`expected an error for bitmap 254 with no earlier bitmap, got nil`)
+	}
+}
+
+func TestReadNoMatch(t *testing.T) {
+	stream := testMessage{values: []uint16{0, 0, 0, 0, 0, 0}}.build()
+	_, _, err := grib.Read(bytes.NewReader(stream), grib.All(grib.MessageNumber(1), grib.Level(100, 50000)))
+	if !errors.Is(err, grib.ErrNotFound) {
+		t.Fatalf(`expected ErrNotFound, got %v`, err)
+	}
+}
+
+func TestReadNormalizesScanMode(t *testing.T) {
+	cases := map[string]struct {
+		scanMode byte
+		values   []uint16
+	}{
+		`north-to-south`:  {scanMode: 0x00, values: []uint16{3, 4, 5, 0, 1, 2}},
+		`south-to-north`:  {scanMode: 0x40, values: []uint16{0, 1, 2, 3, 4, 5}},
+		`east-to-west`:    {scanMode: 0xc0, values: []uint16{2, 1, 0, 5, 4, 3}},
+		`column-major`:    {scanMode: 0x60, values: []uint16{0, 3, 1, 4, 2, 5}},
+		`boustrophedon`:   {scanMode: 0x50, values: []uint16{0, 1, 2, 5, 4, 3}},
+		`east-north-down`: {scanMode: 0x80, values: []uint16{5, 4, 3, 2, 1, 0}},
+	}
+	for name, c := range cases {
+		stream := testMessage{scanMode: c.scanMode, values: c.values}.build()
+		grid, _, err := grib.Read(bytes.NewReader(stream), grib.MessageNumber(1))
+		if err != nil {
+			t.Fatalf(`%v: %v`, name, err)
+		}
+		expectedGeometry := grid_to_isobands.RegularLatLon{Lat0: 49, Lon0: -100, DLat: 1, DLon: 1}
+		if !reflect.DeepEqual(grid.Geometry, expectedGeometry) {
+			t.Fatalf(`%v: expected geometry %v, got %v`, name, expectedGeometry, grid.Geometry)
+		}
+		assertValues(t, grid.Values, []float64{100, 101, 102, 103, 104, 105})
+	}
+}
+
+func TestReadRotatesGlobalGrid(t *testing.T) {
+	stream := testMessage{
+		scanMode: 0x40,
+		values:   []uint16{0, 1, 2, 3, 4, 5, 6, 7},
+		firstLon: 1e6,
+		lonStep:  90e6,
+	}.build()
+	grid, _, err := grib.Read(bytes.NewReader(stream), grib.MessageNumber(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Columns at 1, 91, 181 and 271 degrees east start over at -179.
+	expectedGeometry := grid_to_isobands.RegularLatLon{Lat0: 49, Lon0: -179, DLat: 1, DLon: 90}
+	if !reflect.DeepEqual(grid.Geometry, expectedGeometry) {
+		t.Fatalf(`expected geometry %v, got %v`, expectedGeometry, grid.Geometry)
+	}
+	assertValues(t, grid.Values, []float64{102, 103, 100, 101, 106, 107, 104, 105})
+}
+
+func assertValues(t *testing.T, got, expected []float64) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf(`expected values %v, got %v`, expected, got)
+	}
+	for i, v := range got {
+		if v != expected[i] && !(math.IsNaN(v) && math.IsNaN(expected[i])) {
+			t.Fatalf(`expected values %v, got %v`, expected, got)
+		}
+	}
+}
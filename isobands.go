@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"math"
 	"os"
 	"os/exec"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/fxamacker/cbor"
 	"github.com/google/uuid"
+	"github.com/paulmach/orb"
 )
 
 //go:embed isobands.py
@@ -26,6 +28,12 @@ type GridValues struct {
 	Values []float64
 	Lats   []float64
 	Lons   []float64
+	// Geometry, when set, describes the point coordinates in place of Lats
+	// and Lons, which are then left empty.
+	Geometry GridGeometry
+	// Unit, when set, is the unit of Values. It is recorded in the
+	// properties of the isobands under "unit" (see UnitTransformer).
+	Unit Unit
 }
 
 type IsobandArgs struct {
@@ -34,6 +42,11 @@ type IsobandArgs struct {
 	Floor, Step  float64
 	AddlProps    map[string]any
 	WorkDir      string
+	// Mask, when set, clips every isoband to the given orb.Polygon,
+	// orb.MultiPolygon, or orb.Bound (in the same lon/lat coordinates as the
+	// grid). The intersection is done by shapely in the Python subprocess, so
+	// band polygons keep their level properties after clipping.
+	Mask orb.Geometry
 }
 
 // pyArgs is the wire format sent to the Python isoband-generation subprocess.
@@ -43,13 +56,20 @@ type IsobandArgs struct {
 // floats first. For large grids (tens of millions of points), that
 // intermediate list roughly doubles peak memory in the subprocess before any
 // contouring work even starts.
+//
+// When the grid is rectilinear, XAxis (SizeX longitudes) and YAxis (SizeY
+// latitudes) are sent instead of Lats and Lons, so neither the Go side nor
+// the input file has to carry full per-point coordinate arrays.
 type pyArgs struct {
 	SizeX  int
 	SizeY  int
 	Values []byte
 	Lats   []byte
 	Lons   []byte
+	XAxis  []byte
+	YAxis  []byte
 	Levels []float64
+	Mask   []orb.Polygon
 }
 
 // packFloat64 packs a []float64 into a little-endian byte buffer for the
@@ -62,13 +82,66 @@ func packFloat64(values []float64) []byte {
 	return buf
 }
 
+// packCoordinates fills the coordinate fields of pyData, preferring 1D axes
+// whenever the grid's geometry (or its per-point coordinates) is rectilinear.
+// Projected grids send their x/y axes, so contouring happens in the native
+// projection.
+func packCoordinates(pyData *pyArgs, grid *GridValues) {
+	if projected, ok := grid.Geometry.(ProjectedGrid); ok {
+		xs, ys := projected.axes(grid.SizeX, grid.SizeY)
+		pyData.XAxis = packFloat64(xs)
+		pyData.YAxis = packFloat64(ys)
+		return
+	}
+	axes, ok := toAxes(grid.Geometry, grid.SizeX, grid.SizeY)
+	if !ok && grid.Geometry == nil {
+		axes, ok = rectilinearAxes(grid.Lats, grid.Lons, grid.SizeX, grid.SizeY)
+	}
+	if ok {
+		pyData.XAxis = packFloat64(axes.Lons)
+		pyData.YAxis = packFloat64(axes.Lats)
+		return
+	}
+	lats, lons := grid.Lats, grid.Lons
+	if grid.Geometry != nil {
+		expanded := &GridValues{SizeX: grid.SizeX, SizeY: grid.SizeY, Geometry: grid.Geometry}
+		expanded.ExpandCoordinates()
+		lats, lons = expanded.Lats, expanded.Lons
+	}
+	pyData.Lats = packFloat64(lats)
+	pyData.Lons = packFloat64(lons)
+}
+
+// projectPolygons returns copies of polygons with every ring passed through
+// project.
+func projectPolygons(polygons []orb.Polygon, project func(orb.Ring) orb.Ring) []orb.Polygon {
+	projected := make([]orb.Polygon, len(polygons))
+	for i, polygon := range polygons {
+		projected[i] = make(orb.Polygon, len(polygon))
+		for j, ring := range polygon {
+			projected[i][j] = project(ring)
+		}
+	}
+	return projected
+}
+
 type ReturnValues struct {
 	Isobands *FeatureCollection
 	Grid     *GridValues
 }
 
+// IsobandsFromGrid validates args.Grid (see GridValues.Validate), runs its
+// preprocesses and contours the result.
 func IsobandsFromGrid(ctx context.Context, args *IsobandArgs) (*ReturnValues, error) {
-	preprocessGrid(args)
+	if args.Grid == nil {
+		return nil, fmt.Errorf("error generating isobands: no grid")
+	}
+	if err := args.Grid.Validate(); err != nil {
+		return nil, fmt.Errorf("error generating isobands: %w", err)
+	}
+	if err := preprocessGrid(ctx, args); err != nil {
+		return nil, fmt.Errorf("error generating isobands: %w", err)
+	}
 	isobands, err := toIsobands(ctx, args)
 	if err != nil {
 		return nil, fmt.Errorf("error generating isobands: %w", err)
@@ -88,10 +161,33 @@ func preprocessArgs(args *IsobandArgs) {
 	args.Floor = floor
 }
 
-func preprocessGrid(args *IsobandArgs) {
-	for _, preprocess := range args.Preprocesses {
-		preprocess(args.Grid)
+// PreprocessError reports which of IsobandArgs.Preprocesses failed. Step is
+// its index in the slice.
+type PreprocessError struct {
+	Step int
+	Err  error
+}
+
+func (e *PreprocessError) Error() string {
+	return fmt.Sprintf("preprocess step %d failed: %v", e.Step, e.Err)
+}
+
+func (e *PreprocessError) Unwrap() error {
+	return e.Err
+}
+
+// preprocessGrid runs the preprocesses in order, stopping before the next
+// step once ctx is cancelled.
+func preprocessGrid(ctx context.Context, args *IsobandArgs) error {
+	for i, preprocess := range args.Preprocesses {
+		if err := ctx.Err(); err != nil {
+			return &PreprocessError{Step: i, Err: err}
+		}
+		if err := preprocess(ctx, args.Grid); err != nil {
+			return &PreprocessError{Step: i, Err: err}
+		}
 	}
+	return nil
 }
 
 func GenerateLevels(start, stop, step float64) []float64 {
@@ -108,17 +204,27 @@ func toIsobands(ctx context.Context, args *IsobandArgs) (*FeatureCollection, err
 	preprocessArgs(args)
 	maxVal := slicesMaxNotNaN(args.Grid.Values)
 	if math.IsNaN(maxVal) {
-		return &FeatureCollection{Features: []Feature{}}, nil
+		isobands := &FeatureCollection{Features: []Feature{}}
+		setProperties(isobands, args)
+		return isobands, nil
+	}
+	mask, err := maskPolygons(args.Mask)
+	if err != nil {
+		return nil, fmt.Errorf("error generating isobands: invalid mask: %w", err)
+	}
+	projected, isProjected := args.Grid.Geometry.(ProjectedGrid)
+	if isProjected {
+		mask = projectPolygons(mask, projected.fromLonLat)
 	}
 	jobId := uuid.NewString()
 	pyData := &pyArgs{
 		SizeX:  args.Grid.SizeX,
 		SizeY:  args.Grid.SizeY,
 		Values: packFloat64(args.Grid.Values),
-		Lats:   packFloat64(args.Grid.Lats),
-		Lons:   packFloat64(args.Grid.Lons),
 		Levels: GenerateLevels(args.Floor, maxVal, args.Step),
+		Mask:   mask,
 	}
+	packCoordinates(pyData, args.Grid)
 
 	inPath := gridPath(jobId, args.WorkDir)
 	in, err := os.Create(inPath)
@@ -153,16 +259,36 @@ func toIsobands(ctx context.Context, args *IsobandArgs) (*FeatureCollection, err
 	if err != nil {
 		return nil, fmt.Errorf("error generating isobands: failed to decode isobands: %w", err)
 	}
-	for i, feature := range isobands.Features {
-		if feature.Geometry.Coordinates == nil {
-			slices.Delete(isobands.Features, i, i+1)
-			i--
+	isobands.Features = slices.DeleteFunc(isobands.Features, func(feature Feature) bool {
+		return feature.Geometry.Coordinates == nil
+	})
+	if isProjected {
+		for i := range isobands.Features {
+			feature := &isobands.Features[i]
+			feature.Geometry.Coordinates = projectPolygons(
+				[]orb.Polygon{feature.Geometry.Coordinates}, projected.toLonLat)[0]
 		}
 	}
-	isobands.Properties = args.AddlProps
+	setProperties(isobands, args)
 	return isobands, nil
 }
 
+// setProperties gives the collection args.AddlProps as its properties and
+// records the grid's unit, if it has one, on it and on every feature.
+func setProperties(isobands *FeatureCollection, args *IsobandArgs) {
+	isobands.Properties = args.AddlProps
+	if unit := args.Grid.Unit; unit != "" {
+		isobands.Properties = maps.Clone(isobands.Properties)
+		if isobands.Properties == nil {
+			isobands.Properties = map[string]any{}
+		}
+		isobands.Properties[unitProperty] = string(unit)
+		for i := range isobands.Features {
+			isobands.Features[i].Properties[unitProperty] = string(unit)
+		}
+	}
+}
+
 func slicesMaxNotNaN(s []float64) float64 {
 	maxVal := math.NaN()
 	for _, n := range s {
@@ -47,11 +47,40 @@ def ring_area(ring):
     return abs(area) / 2.0
 
 
-def grid_to_isobands(values, lats, lons, nx, ny, levels):
-    xi_grid = np.reshape(lons, (ny, nx))
-    yi_grid = np.reshape(lats, (ny, nx))
+def build_mask(polygons):
+    # The mask arrives as a list of polygons, each a list of rings (shell
+    # first, then holes). Parts are repaired individually and unioned so
+    # overlapping or self-intersecting input (common in hand-drawn service
+    # territories) still yields a single valid clipping geometry.
+    parts = []
+    for rings in polygons:
+        if not rings or len(rings[0]) < 4:
+            continue
+        parts.append(make_valid(Polygon(rings[0], [ring for ring in rings[1:] if len(ring) >= 4])))
+    if not parts:
+        return None
+    mask = shapely.union_all(parts)
+    shapely.prepare(mask)
+    return mask
+
+
+def clip_to_mask(geom, mask):
+    # Bands entirely inside or outside the mask skip the overlay entirely.
+    # Otherwise the intersection is computed with a fixed grid_size, which
+    # makes shapely use snap-rounding overlay -- robust against the nearly
+    # collinear edges contour output shares with mask boundaries.
+    if not mask.intersects(geom):
+        return None
+    if mask.contains(geom):
+        return geom
+    return shapely.intersection(geom, mask, grid_size=1e-9)
+
+
+def grid_to_isobands(values, x, y, nx, ny, levels, mask=None):
+    # x and y are either 1D axes (length nx and ny) or full (ny, nx) arrays;
+    # contourpy accepts both.
     zi_grid = np.reshape(values, (ny, nx))
-    cont_gen = contourpy.contour_generator(x=xi_grid, y=yi_grid, z=zi_grid, name='serial',
+    cont_gen = contourpy.contour_generator(x=x, y=y, z=zi_grid, name='serial',
                                            fill_type=contourpy.FillType.ChunkCombinedOffsetOffset,
                                            quad_as_tri=True)
 
@@ -104,6 +133,10 @@ def grid_to_isobands(values, lats, lons, nx, ny, levels):
                     # legitimately close vertices and introduce a *new*
                     # self-intersection instead of removing one.
                     cleaned = shapely.set_precision(repaired, grid_size=1e-9)
+                    if mask is not None:
+                        cleaned = clip_to_mask(cleaned, mask)
+                        if cleaned is None:
+                            continue
                     for polygon in polygon_geoms(cleaned):
                         features.append({
                             "type": "Feature",
@@ -135,12 +168,18 @@ if __name__ == '__main__':
     # Values/Lats/Lons now arrive as packed little-endian float64 byte strings rather than CBOR
     # arrays, so np.frombuffer wraps them directly with no intermediate Python list of boxed floats.
     vals = np.frombuffer(data['Values'], dtype='<f8')
-    lats = np.frombuffer(data['Lats'], dtype='<f8')
-    lons = np.frombuffer(data['Lons'], dtype='<f8')
+    if data.get('XAxis'):
+        # Rectilinear grids send only their 1D axes.
+        x = np.frombuffer(data['XAxis'], dtype='<f8')
+        y = np.frombuffer(data['YAxis'], dtype='<f8')
+    else:
+        x = np.reshape(np.frombuffer(data['Lons'], dtype='<f8'), (rows, cols))
+        y = np.reshape(np.frombuffer(data['Lats'], dtype='<f8'), (rows, cols))
     levels = np.array(data['Levels'])
+    mask = build_mask(data['Mask']) if data.get('Mask') else None
     del data
 
-    isobands = grid_to_isobands(vals, lats, lons, cols, rows, levels)
+    isobands = grid_to_isobands(vals, x, y, cols, rows, levels, mask)
 
     with open(out_path, 'w') as outf:
         json.dump(isobands, outf)
@@ -96,6 +96,61 @@ func TestMrmsBaseReflectivity2(t *testing.T) {
 	}
 }
 
+func TestMrmsBaseReflectivityMasked(t *testing.T) {
+	testData, err := getTestData(`mrms-base-reflectivity.json`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gridValues := &grid_to_isobands.GridValues{
+		SizeX:  testData.SizeX,
+		SizeY:  testData.SizeY,
+		Lats:   testData.Lats,
+		Lons:   testData.Lngs,
+		Values: testData.Values,
+	}
+	mask, err := grid_to_isobands.MaskFromGeoJSON([]byte(`{
+		"type": "Polygon",
+		"coordinates": [[[-104, 30], [-90, 30], [-90, 42], [-97, 46], [-104, 42], [-104, 30]]]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := &grid_to_isobands.IsobandArgs{
+		Preprocesses: []grid_to_isobands.GridTransformer{
+			grid_to_isobands.BilateralTransformer(1, 2.5),
+			grid_to_isobands.CloseOpenTransformer(3),
+			grid_to_isobands.GaussianTransformer(5, 1.0),
+		},
+		Grid:  gridValues,
+		Floor: 5,
+		Step:  2.5,
+		AddlProps: map[string]any{
+			`measure`: `base-reflectivity`,
+			`at`:      time.Date(2025, 12, 11, 23, 59, 17, 0, time.UTC),
+		},
+		Mask: mask,
+	}
+	isobands, err := grid_to_isobands.IsobandsFromGrid(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, feature := range isobands.Isobands.Features {
+		if _, ok := feature.Properties[`floor`]; !ok {
+			t.Fatalf(`expected clipped feature to keep its level properties, got %v`, feature.Properties)
+		}
+		for _, ring := range feature.Geometry.Coordinates {
+			bound := ring.Bound()
+			if bound.Min.Lon() < -104-1e-6 || bound.Max.Lon() > -90+1e-6 || bound.Min.Lat() < 30-1e-6 || bound.Max.Lat() > 46+1e-6 {
+				t.Fatalf(`expected feature inside the mask, got bound %v`, bound)
+			}
+		}
+	}
+	err = saveTestOutput(isobands, `mrms-base-reflectivity-masked.geojson`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestMrmsKnoxTornadoBaseReflectivity(t *testing.T) {
 	testData, err := getTestData(`mrms-knox-tornado-base-reflectivity.json`)
 	if err != nil {
@@ -329,6 +384,53 @@ func TestSurfaceTemp(t *testing.T) {
 	}
 }
 
+func TestSurfaceTempFahrenheit(t *testing.T) {
+	testData, err := getTestData(`temperature-surface.json`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gridValues := &grid_to_isobands.GridValues{
+		SizeX:  testData.SizeX,
+		SizeY:  testData.SizeY,
+		Lats:   testData.Lats,
+		Lons:   testData.Lngs,
+		Values: testData.Values,
+		Unit:   grid_to_isobands.Kelvin,
+	}
+	args := &grid_to_isobands.IsobandArgs{
+		Preprocesses: []grid_to_isobands.GridTransformer{
+			grid_to_isobands.SwapRightAndLeftTransformer(),
+			grid_to_isobands.UnitTransformer("", grid_to_isobands.Fahrenheit),
+			grid_to_isobands.GaussianTransformer(7, 1),
+		},
+		Grid:  gridValues,
+		Floor: -60,
+		Step:  5,
+		AddlProps: map[string]any{
+			`measure`: `temperature-surface`,
+		},
+		WorkDir: "./tmp",
+	}
+	isogons, err := grid_to_isobands.IsobandsFromGrid(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit := isogons.Isobands.Properties[`unit`]; unit != `degF` {
+		t.Fatalf(`expected degF, got %v`, unit)
+	}
+	for _, feature := range isogons.Isobands.Features {
+		if unit := feature.Properties[`unit`]; unit != `degF` {
+			t.Fatalf(`expected degF, got %v`, unit)
+		}
+		if floor := feature.Properties[`floor`].(float64); floor < -60 || floor > 140 {
+			t.Fatalf(`expected a floor in degrees Fahrenheit, got %v`, floor)
+		}
+	}
+	if _, ok := args.AddlProps[`unit`]; ok {
+		t.Fatalf(`expected AddlProps to be left alone, got %v`, args.AddlProps)
+	}
+}
+
 /*
 	func TestWindU(t *testing.T) {
 		testData, err := getTestData(`wind-u-100hpa.json`)
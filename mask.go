@@ -0,0 +1,92 @@
+package grid_to_isobands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/paulmach/orb"
+)
+
+// maskPolygons normalizes a clip mask into the list of polygons sent to the
+// Python subprocess. Only polygonal geometries can be used as a mask.
+func maskPolygons(mask orb.Geometry) ([]orb.Polygon, error) {
+	switch m := mask.(type) {
+	case nil:
+		return nil, nil
+	case orb.Polygon:
+		if len(m) == 0 {
+			return nil, fmt.Errorf("mask polygon has no rings")
+		}
+		return []orb.Polygon{m}, nil
+	case orb.MultiPolygon:
+		if len(m) == 0 {
+			return nil, fmt.Errorf("mask multipolygon has no polygons")
+		}
+		return m, nil
+	case orb.Bound:
+		return []orb.Polygon{m.ToPolygon()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mask geometry type %v", mask.GeoJSONType())
+	}
+}
+
+// MaskFromGeoJSON parses a GeoJSON Polygon or MultiPolygon geometry, Feature,
+// or FeatureCollection into a mask usable as IsobandArgs.Mask. Polygonal
+// features in a collection are combined; other geometry types are ignored.
+func MaskFromGeoJSON(data []byte) (orb.MultiPolygon, error) {
+	mask, err := polygonsFromGeoJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(mask) == 0 {
+		return nil, fmt.Errorf("error decoding mask: no polygons found")
+	}
+	return mask, nil
+}
+
+func polygonsFromGeoJSON(data []byte) (orb.MultiPolygon, error) {
+	var object struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometry    json.RawMessage   `json:"geometry"`
+		Features    []json.RawMessage `json:"features"`
+	}
+	err := json.Unmarshal(data, &object)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding mask: %w", err)
+	}
+
+	switch object.Type {
+	case "Polygon":
+		var polygon orb.Polygon
+		err = json.Unmarshal(object.Coordinates, &polygon)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding mask polygon: %w", err)
+		}
+		return orb.MultiPolygon{polygon}, nil
+	case "MultiPolygon":
+		var multiPolygon orb.MultiPolygon
+		err = json.Unmarshal(object.Coordinates, &multiPolygon)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding mask multipolygon: %w", err)
+		}
+		return multiPolygon, nil
+	case "Feature":
+		if len(object.Geometry) == 0 || string(object.Geometry) == "null" {
+			return nil, nil
+		}
+		return polygonsFromGeoJSON(object.Geometry)
+	case "FeatureCollection":
+		var mask orb.MultiPolygon
+		for i, feature := range object.Features {
+			polygons, err := polygonsFromGeoJSON(feature)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding mask feature %d: %w", i, err)
+			}
+			mask = append(mask, polygons...)
+		}
+		return mask, nil
+	default:
+		return nil, nil
+	}
+}
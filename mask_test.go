@@ -0,0 +1,41 @@
+package grid_to_isobands_test
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+)
+
+func TestMaskFromGeoJSONFeatureCollection(t *testing.T) {
+	data := []byte(`{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [0, 0]}},
+			{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}},
+			{"type": "Feature", "properties": {}, "geometry": {"type": "MultiPolygon", "coordinates": [
+				[[[2, 2], [3, 2], [3, 3], [2, 2]]],
+				[[[4, 4], [5, 4], [5, 5], [4, 4]]]
+			]}}
+		]
+	}`)
+	mask, err := grid_to_isobands.MaskFromGeoJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := orb.MultiPolygon{
+		{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		{{{2, 2}, {3, 2}, {3, 3}, {2, 2}}},
+		{{{4, 4}, {5, 4}, {5, 5}, {4, 4}}},
+	}
+	if !mask.Equal(expected) {
+		t.Fatalf(`expected %v, got %v`, expected, mask)
+	}
+}
+
+func TestMaskFromGeoJSONWithoutPolygons(t *testing.T) {
+	_, err := grid_to_isobands.MaskFromGeoJSON([]byte(`{"type": "Point", "coordinates": [0, 0]}`))
+	if err == nil {
+		t.Fatal(`expected an error for a mask without polygons`)
+	}
+}
@@ -0,0 +1,336 @@
+package netcdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// NetCDF classic external data types.
+const (
+	ncByte   = 1
+	ncChar   = 2
+	ncShort  = 3
+	ncInt    = 4
+	ncFloat  = 5
+	ncDouble = 6
+	ncUByte  = 7
+	ncUShort = 8
+	ncUInt   = 9
+	ncInt64  = 10
+	ncUInt64 = 11
+)
+
+// Header list tags.
+const (
+	tagDimension = 0x0a
+	tagVariable  = 0x0b
+	tagAttribute = 0x0c
+)
+
+// ErrNetCDF4 is returned for NetCDF-4 (HDF5-based) files, which this package
+// does not read. Convert them with `nccopy -k classic` (or `-k cdf5` for
+// variables over 4 GiB) first.
+var ErrNetCDF4 = errors.New("NetCDF-4/HDF5 files are not supported")
+
+type dimension struct {
+	name   string
+	length int64
+}
+
+type attribute struct {
+	name   string
+	ncType int
+	values []float64
+	text   string
+	raw    []byte
+}
+
+type variable struct {
+	name   string
+	dims   []int
+	attrs  []attribute
+	ncType int
+	vsize  int64
+	begin  int64
+}
+
+type header struct {
+	version    byte
+	numRecs    int64
+	dims       []dimension
+	attrs      []attribute
+	vars       []variable
+	recordSize int64
+}
+
+func (h *header) variable(name string) (*variable, bool) {
+	for i := range h.vars {
+		if h.vars[i].name == name {
+			return &h.vars[i], true
+		}
+	}
+	return nil, false
+}
+
+func (h *header) isRecord(v *variable) bool {
+	return len(v.dims) > 0 && h.dims[v.dims[0]].length == 0
+}
+
+func (v *variable) attr(name string) (*attribute, bool) {
+	for i := range v.attrs {
+		if v.attrs[i].name == name {
+			return &v.attrs[i], true
+		}
+	}
+	return nil, false
+}
+
+// packedAttr returns a numeric attribute's values decoded the way the
+// variable's data is, so the fill and valid range attributes of an _Unsigned
+// variable compare against its unsigned values.
+func (v *variable) packedAttr(name string, unsigned bool) ([]float64, bool) {
+	a, ok := v.attr(name)
+	if !ok {
+		return nil, false
+	}
+	if unsigned && a.ncType != ncChar {
+		return decodeValues(a.raw, a.ncType, true), true
+	}
+	return a.values, true
+}
+
+// textAttr returns a character attribute's value, or "" if it is missing.
+func (v *variable) textAttr(name string) string {
+	if a, ok := v.attr(name); ok {
+		return strings.TrimRight(a.text, "\x00 ")
+	}
+	return ""
+}
+
+// headerReader decodes the big-endian header, tracking the format version so
+// it knows whether counts and offsets are 4 or 8 bytes wide.
+type headerReader struct {
+	r       io.Reader
+	version byte
+	err     error
+}
+
+func (hr *headerReader) read(n int) []byte {
+	if hr.err != nil {
+		return make([]byte, n)
+	}
+	buf := make([]byte, n)
+	_, hr.err = io.ReadFull(hr.r, buf)
+	return buf
+}
+
+func (hr *headerReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(hr.read(4))
+}
+
+// count reads a NON_NEG value: 4 bytes, or 8 bytes in CDF-5.
+func (hr *headerReader) count() int64 {
+	if hr.version == 5 {
+		return int64(binary.BigEndian.Uint64(hr.read(8)))
+	}
+	return int64(hr.uint32())
+}
+
+// offset reads a variable's begin offset: 4 bytes in CDF-1, 8 otherwise.
+func (hr *headerReader) offset() int64 {
+	if hr.version == 1 {
+		return int64(hr.uint32())
+	}
+	return int64(binary.BigEndian.Uint64(hr.read(8)))
+}
+
+func (hr *headerReader) name() string {
+	n := hr.count()
+	if hr.err == nil && (n < 0 || n > 1<<20) {
+		hr.err = fmt.Errorf("invalid name length %d", n)
+	}
+	if hr.err != nil {
+		return ""
+	}
+	return string(hr.read(int(padded(n)))[:n])
+}
+
+// listHeader reads a list tag and its element count, accepting ABSENT.
+func (hr *headerReader) listHeader(tag uint32) int64 {
+	got := hr.uint32()
+	n := hr.count()
+	if hr.err == nil && got != tag && !(got == 0 && n == 0) {
+		hr.err = fmt.Errorf("expected list tag %#x, got %#x", tag, got)
+	}
+	if hr.err == nil && (n < 0 || n > 1<<24) {
+		hr.err = fmt.Errorf("invalid list length %d", n)
+	}
+	return n
+}
+
+func (hr *headerReader) attributes() []attribute {
+	n := hr.listHeader(tagAttribute)
+	attrs := make([]attribute, 0, n)
+	for i := int64(0); i < n && hr.err == nil; i++ {
+		a := attribute{name: hr.name(), ncType: int(hr.uint32())}
+		count := hr.count()
+		size := typeSize(a.ncType)
+		if hr.err == nil && (size == 0 || count < 0 || count > 1<<24) {
+			hr.err = fmt.Errorf("invalid attribute %v", a.name)
+		}
+		if hr.err != nil {
+			break
+		}
+		a.raw = hr.read(int(padded(count * size)))[:count*size]
+		if a.ncType == ncChar {
+			a.text = string(a.raw)
+		} else {
+			a.values = decodeValues(a.raw, a.ncType, false)
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+func readHeader(r io.Reader) (*header, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("error reading magic number: %w", err)
+	}
+	if string(magic) == "\x89HDF" {
+		return nil, ErrNetCDF4
+	}
+	if string(magic[:3]) != "CDF" || (magic[3] != 1 && magic[3] != 2 && magic[3] != 5) {
+		return nil, fmt.Errorf("not a NetCDF classic file")
+	}
+
+	hr := &headerReader{r: r, version: magic[3]}
+	h := &header{version: magic[3], numRecs: hr.count()}
+	if hr.version != 5 && h.numRecs == math.MaxUint32 {
+		// Streaming: the writer didn't record the count. It stays unknown, so
+		// record indices aren't range checked and reading past the last
+		// record fails when the data is read.
+		h.numRecs = -1
+	}
+
+	nDims := hr.listHeader(tagDimension)
+	for i := int64(0); i < nDims && hr.err == nil; i++ {
+		h.dims = append(h.dims, dimension{name: hr.name(), length: hr.count()})
+	}
+	h.attrs = hr.attributes()
+
+	nVars := hr.listHeader(tagVariable)
+	var recordVars int
+	for i := int64(0); i < nVars && hr.err == nil; i++ {
+		v := variable{name: hr.name()}
+		nd := hr.count()
+		for j := int64(0); j < nd && hr.err == nil; j++ {
+			id := int(hr.count())
+			if id < 0 || id >= len(h.dims) {
+				hr.err = fmt.Errorf("variable %v has invalid dimension id %d", v.name, id)
+			}
+			v.dims = append(v.dims, id)
+		}
+		v.attrs = hr.attributes()
+		v.ncType = int(hr.uint32())
+		v.vsize = hr.count()
+		v.begin = hr.offset()
+		if hr.err == nil && typeSize(v.ncType) == 0 {
+			hr.err = fmt.Errorf("variable %v has unsupported type %d", v.name, v.ncType)
+		}
+		h.vars = append(h.vars, v)
+		if hr.err == nil && h.isRecord(&v) {
+			recordVars++
+			h.recordSize += padded(v.vsize)
+		}
+	}
+	if hr.err != nil {
+		return nil, fmt.Errorf("error reading header: %w", hr.err)
+	}
+	// A lone record variable isn't padded between records.
+	if recordVars == 1 {
+		for i := range h.vars {
+			if h.isRecord(&h.vars[i]) {
+				h.recordSize = h.vars[i].sliceBytes(h)
+			}
+		}
+	}
+	return h, nil
+}
+
+// sliceBytes returns the unpadded size of one record (or the whole variable
+// for non-record variables).
+func (v *variable) sliceBytes(h *header) int64 {
+	size := typeSize(v.ncType)
+	for i, id := range v.dims {
+		if i == 0 && h.isRecord(v) {
+			continue
+		}
+		size *= h.dims[id].length
+	}
+	return size
+}
+
+func padded(n int64) int64 {
+	return (n + 3) &^ 3
+}
+
+func typeSize(ncType int) int64 {
+	switch ncType {
+	case ncByte, ncChar, ncUByte:
+		return 1
+	case ncShort, ncUShort:
+		return 2
+	case ncInt, ncFloat, ncUInt:
+		return 4
+	case ncDouble, ncInt64, ncUInt64:
+		return 8
+	}
+	return 0
+}
+
+// decodeValues converts big-endian external data to float64. unsigned
+// reinterprets signed byte and short data, per the CF _Unsigned convention.
+func decodeValues(raw []byte, ncType int, unsigned bool) []float64 {
+	size := typeSize(ncType)
+	values := make([]float64, int64(len(raw))/size)
+	be := binary.BigEndian
+	for i := range values {
+		b := raw[int64(i)*size:]
+		switch ncType {
+		case ncByte:
+			if unsigned {
+				values[i] = float64(b[0])
+			} else {
+				values[i] = float64(int8(b[0]))
+			}
+		case ncUByte, ncChar:
+			values[i] = float64(b[0])
+		case ncShort:
+			if unsigned {
+				values[i] = float64(be.Uint16(b))
+			} else {
+				values[i] = float64(int16(be.Uint16(b)))
+			}
+		case ncUShort:
+			values[i] = float64(be.Uint16(b))
+		case ncInt:
+			values[i] = float64(int32(be.Uint32(b)))
+		case ncUInt:
+			values[i] = float64(be.Uint32(b))
+		case ncFloat:
+			values[i] = float64(math.Float32frombits(be.Uint32(b)))
+		case ncDouble:
+			values[i] = math.Float64frombits(be.Uint64(b))
+		case ncInt64:
+			values[i] = float64(int64(be.Uint64(b)))
+		case ncUInt64:
+			values[i] = float64(be.Uint64(b))
+		}
+	}
+	return values
+}
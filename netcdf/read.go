@@ -0,0 +1,296 @@
+// Package netcdf builds GridValues from variables in NetCDF classic files.
+//
+// The classic (CDF-1), 64-bit offset (CDF-2) and 64-bit data (CDF-5) formats
+// are read in pure Go. NetCDF-4 files are HDF5 containers, which would need a
+// full HDF5 implementation; they are detected and rejected with ErrNetCDF4.
+package netcdf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+)
+
+// Selection picks the variable and the 2D slice of it to read. The last two
+// dimensions of the variable are taken as its rows and columns; up to two
+// leading dimensions are indexed by Time and Level, following the CF
+// (T, Z, Y, X) ordering. A single leading dimension is treated as time when
+// it is the record dimension or its coordinate variable looks like time,
+// and as the level otherwise.
+type Selection struct {
+	Variable string
+	Time     int
+	Level    int
+}
+
+// ErrVariableNotFound is returned when the file has no variable with the
+// selected name.
+var ErrVariableNotFound = errors.New("variable not found")
+
+// defaultFill is the NetCDF default fill value for float and double data,
+// used when a variable doesn't declare _FillValue.
+const defaultFill = 9.9692099683868690e+36
+
+// Read extracts the selected slice of a variable as a GridValues ready for
+// IsobandsFromGrid. Packed values are unpacked with scale_factor and
+// add_offset, and points equal to _FillValue or missing_value, or outside
+// valid_range/valid_min/valid_max, are NaN.
+//
+// Coordinates come from the 1D coordinate variables of the row and column
+// dimensions, which give a LatLonAxes geometry, or failing that from the 2D
+// latitude and longitude variables named in the CF coordinates attribute,
+// which give per-point Lats and Lons. Variables stored as (lon, lat) are
+// transposed so rows always follow latitude.
+func Read(r io.ReaderAt, selection Selection) (*grid_to_isobands.GridValues, error) {
+	h, err := readHeader(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, fmt.Errorf("error reading NetCDF file: %w", err)
+	}
+	v, ok := h.variable(selection.Variable)
+	if !ok {
+		return nil, fmt.Errorf("error reading NetCDF variable %v: %w", selection.Variable, ErrVariableNotFound)
+	}
+	values, err := h.readSlice(r, v, selection)
+	if err != nil {
+		return nil, fmt.Errorf("error reading NetCDF variable %v: %w", v.name, err)
+	}
+
+	yDim, xDim := v.dims[len(v.dims)-2], v.dims[len(v.dims)-1]
+	grid := &grid_to_isobands.GridValues{
+		SizeX:  int(h.dims[xDim].length),
+		SizeY:  int(h.dims[yDim].length),
+		Values: values,
+		Unit:   grid_to_isobands.Unit(v.textAttr(`units`)),
+	}
+	err = h.resolveCoordinates(r, v, grid)
+	if err != nil {
+		return nil, fmt.Errorf("error reading coordinates of %v: %w", v.name, err)
+	}
+	return grid, nil
+}
+
+// ReadFile opens path and reads the selected variable from it.
+func ReadFile(path string, selection Selection) (*grid_to_isobands.GridValues, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening NetCDF file: %w", err)
+	}
+	defer f.Close()
+	return Read(f, selection)
+}
+
+// readSlice reads and unpacks the 2D slab of v picked by selection.
+func (h *header) readSlice(r io.ReaderAt, v *variable, selection Selection) ([]float64, error) {
+	if len(v.dims) < 2 || len(v.dims) > 4 {
+		return nil, fmt.Errorf("expected 2 to 4 dimensions, got %d", len(v.dims))
+	}
+	leading := v.dims[:len(v.dims)-2]
+	indices := make([]int64, len(leading))
+	switch {
+	case len(leading) == 2:
+		indices[0], indices[1] = int64(selection.Time), int64(selection.Level)
+	case len(leading) == 1 && h.isTime(leading[0]):
+		indices[0] = int64(selection.Time)
+		if selection.Level != 0 {
+			return nil, fmt.Errorf("level %d selected but the variable has no level dimension", selection.Level)
+		}
+	case len(leading) == 1:
+		indices[0] = int64(selection.Level)
+		if selection.Time != 0 {
+			return nil, fmt.Errorf("time %d selected but the variable has no time dimension", selection.Time)
+		}
+	case selection.Time != 0 || selection.Level != 0:
+		return nil, fmt.Errorf("time or level selected but the variable has only 2 dimensions")
+	}
+
+	record := h.isRecord(v)
+	offset := v.begin
+	stride := v.sliceBytes(h)
+	for i, id := range leading {
+		length := h.dims[id].length
+		if i == 0 && record {
+			length = h.numRecs
+		}
+		if indices[i] < 0 || (length >= 0 && indices[i] >= length) {
+			return nil, fmt.Errorf("index %d out of range for dimension %v of length %d", indices[i], h.dims[id].name, length)
+		}
+		if i == 0 && record {
+			offset += indices[i] * h.recordSize
+			continue
+		}
+		stride /= length
+		offset += indices[i] * stride
+	}
+
+	size := typeSize(v.ncType) * h.dims[v.dims[len(v.dims)-2]].length * h.dims[v.dims[len(v.dims)-1]].length
+	raw := make([]byte, size)
+	if _, err := r.ReadAt(raw, offset); err != nil {
+		return nil, fmt.Errorf("error reading data at offset %d: %w", offset, err)
+	}
+	return unpack(v, raw), nil
+}
+
+// readAll reads and unpacks every value of a non-record variable.
+func (h *header) readAll(r io.ReaderAt, v *variable) ([]float64, error) {
+	if h.isRecord(v) {
+		return nil, fmt.Errorf("coordinate variable %v is a record variable", v.name)
+	}
+	raw := make([]byte, v.sliceBytes(h))
+	if _, err := r.ReadAt(raw, v.begin); err != nil {
+		return nil, fmt.Errorf("error reading %v: %w", v.name, err)
+	}
+	return unpack(v, raw), nil
+}
+
+// unpack decodes raw data and applies the CF packing and missing data
+// attributes. Fill, missing and valid range checks use the packed values,
+// decoded as unsigned along with the data when _Unsigned is set.
+func unpack(v *variable, raw []byte) []float64 {
+	unsigned := strings.EqualFold(v.textAttr(`_Unsigned`), `true`)
+	values := decodeValues(raw, v.ncType, unsigned)
+
+	var missing []float64
+	for _, name := range []string{`_FillValue`, `missing_value`} {
+		if values, ok := v.packedAttr(name, unsigned); ok {
+			missing = append(missing, values...)
+		}
+	}
+	if _, ok := v.attr(`_FillValue`); !ok && (v.ncType == ncFloat || v.ncType == ncDouble) {
+		missing = append(missing, defaultFill)
+	}
+	validMin, validMax := math.Inf(-1), math.Inf(1)
+	if values, ok := v.packedAttr(`valid_range`, unsigned); ok && len(values) == 2 {
+		validMin, validMax = values[0], values[1]
+	}
+	if values, ok := v.packedAttr(`valid_min`, unsigned); ok && len(values) == 1 {
+		validMin = values[0]
+	}
+	if values, ok := v.packedAttr(`valid_max`, unsigned); ok && len(values) == 1 {
+		validMax = values[0]
+	}
+	scale, offset := 1.0, 0.0
+	if a, ok := v.attr(`scale_factor`); ok && len(a.values) == 1 {
+		scale = a.values[0]
+	}
+	if a, ok := v.attr(`add_offset`); ok && len(a.values) == 1 {
+		offset = a.values[0]
+	}
+
+	for i, value := range values {
+		if slices.Contains(missing, value) || value < validMin || value > validMax {
+			values[i] = math.NaN()
+			continue
+		}
+		values[i] = value*scale + offset
+	}
+	return values
+}
+
+// isTime reports whether a dimension is the record dimension or has a
+// coordinate variable that looks like time.
+func (h *header) isTime(dim int) bool {
+	if h.dims[dim].length == 0 {
+		return true
+	}
+	c, ok := h.variable(h.dims[dim].name)
+	if !ok {
+		return strings.EqualFold(h.dims[dim].name, `time`)
+	}
+	return c.textAttr(`axis`) == `T` || c.textAttr(`standard_name`) == `time` ||
+		strings.Contains(c.textAttr(`units`), ` since `)
+}
+
+// resolveCoordinates fills in the grid's geometry or per-point coordinates,
+// transposing the values if the variable is stored as (lon, lat).
+func (h *header) resolveCoordinates(r io.ReaderAt, v *variable, grid *grid_to_isobands.GridValues) error {
+	yDim, xDim := v.dims[len(v.dims)-2], v.dims[len(v.dims)-1]
+	yCoord, yOk := h.coordinateVariable(yDim)
+	xCoord, xOk := h.coordinateVariable(xDim)
+	if yOk && xOk {
+		ys, err := h.readAll(r, yCoord)
+		if err != nil {
+			return err
+		}
+		xs, err := h.readAll(r, xCoord)
+		if err != nil {
+			return err
+		}
+		switch {
+		case isLatitude(yCoord) && isLongitude(xCoord):
+			grid.Geometry = grid_to_isobands.LatLonAxes{Lats: ys, Lons: xs}
+			return nil
+		case isLongitude(yCoord) && isLatitude(xCoord):
+			grid.Values = transpose(grid.Values, grid.SizeX, grid.SizeY)
+			grid.SizeX, grid.SizeY = grid.SizeY, grid.SizeX
+			grid.Geometry = grid_to_isobands.LatLonAxes{Lats: xs, Lons: ys}
+			return nil
+		}
+	}
+
+	var lat, lon *variable
+	for _, name := range strings.Fields(v.textAttr(`coordinates`)) {
+		c, ok := h.variable(name)
+		if !ok || !slices.Equal(c.dims, []int{yDim, xDim}) {
+			continue
+		}
+		if isLatitude(c) {
+			lat = c
+		} else if isLongitude(c) {
+			lon = c
+		}
+	}
+	if lat == nil || lon == nil {
+		return fmt.Errorf("no latitude/longitude coordinate variables for dimensions %v and %v", h.dims[yDim].name, h.dims[xDim].name)
+	}
+	var err error
+	grid.Lats, err = h.readAll(r, lat)
+	if err != nil {
+		return err
+	}
+	grid.Lons, err = h.readAll(r, lon)
+	return err
+}
+
+// coordinateVariable returns the 1D variable named after a dimension.
+func (h *header) coordinateVariable(dim int) (*variable, bool) {
+	c, ok := h.variable(h.dims[dim].name)
+	if !ok || len(c.dims) != 1 || c.dims[0] != dim {
+		return nil, false
+	}
+	return c, true
+}
+
+func isLatitude(v *variable) bool {
+	switch v.textAttr(`units`) {
+	case `degrees_north`, `degree_north`, `degree_N`, `degrees_N`, `degreeN`, `degreesN`:
+		return true
+	}
+	name := strings.ToLower(v.name)
+	return v.textAttr(`standard_name`) == `latitude` || name == `lat` || name == `latitude`
+}
+
+func isLongitude(v *variable) bool {
+	switch v.textAttr(`units`) {
+	case `degrees_east`, `degree_east`, `degree_E`, `degrees_E`, `degreeE`, `degreesE`:
+		return true
+	}
+	name := strings.ToLower(v.name)
+	return v.textAttr(`standard_name`) == `longitude` || name == `lon` || name == `longitude`
+}
+
+// transpose converts rows x cols row-major data to cols x rows.
+func transpose(data []float64, cols, rows int) []float64 {
+	out := make([]float64, len(data))
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			out[x*rows+y] = data[y*cols+x]
+		}
+	}
+	return out
+}
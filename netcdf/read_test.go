@@ -0,0 +1,260 @@
+package netcdf_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	grid_to_isobands "github.com/skysparq/grid-to-isobands"
+	"github.com/skysparq/grid-to-isobands/netcdf"
+)
+
+type testAttr struct {
+	name   string
+	ncType uint32
+	values []float64
+	text   string
+}
+
+type testVar struct {
+	name   string
+	dims   []uint32
+	attrs  []testAttr
+	ncType uint32
+	// data holds every value, records first for record variables.
+	data []float64
+}
+
+type testFile struct {
+	dims    []string
+	lengths []uint32 // 0 marks the record dimension
+	numRecs uint32
+	vars    []testVar
+}
+
+// build encodes a CDF-1 file, laying out non-record data after the header
+// followed by the interleaved records.
+func (f testFile) build() []byte {
+	be := binary.BigEndian
+	pad := func(b []byte) []byte {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	name := func(out []byte, s string) []byte {
+		return pad(append(be.AppendUint32(out, uint32(len(s))), s...))
+	}
+	encode := func(ncType uint32, values []float64) []byte {
+		var out []byte
+		for _, v := range values {
+			switch ncType {
+			case 1:
+				out = append(out, byte(int8(v)))
+			case 3:
+				out = be.AppendUint16(out, uint16(int16(v)))
+			case 5:
+				out = be.AppendUint32(out, math.Float32bits(float32(v)))
+			case 6:
+				out = be.AppendUint64(out, math.Float64bits(v))
+			}
+		}
+		return out
+	}
+	isRecord := func(v testVar) bool { return f.lengths[v.dims[0]] == 0 }
+	sliceLen := func(v testVar) int {
+		n := 1
+		for i, d := range v.dims {
+			if i == 0 && isRecord(v) {
+				continue
+			}
+			n *= int(f.lengths[d])
+		}
+		return n
+	}
+
+	header := func(begins []uint32, vsizes []uint32) []byte {
+		out := append([]byte("CDF\x01"), be.AppendUint32(nil, f.numRecs)...)
+		out = be.AppendUint32(be.AppendUint32(out, 0x0a), uint32(len(f.dims)))
+		for i, d := range f.dims {
+			out = be.AppendUint32(name(out, d), f.lengths[i])
+		}
+		out = append(out, make([]byte, 8)...) // no global attributes
+		out = be.AppendUint32(be.AppendUint32(out, 0x0b), uint32(len(f.vars)))
+		for i, v := range f.vars {
+			out = be.AppendUint32(name(out, v.name), uint32(len(v.dims)))
+			for _, d := range v.dims {
+				out = be.AppendUint32(out, d)
+			}
+			if len(v.attrs) == 0 {
+				out = append(out, make([]byte, 8)...)
+			} else {
+				out = be.AppendUint32(be.AppendUint32(out, 0x0c), uint32(len(v.attrs)))
+			}
+			for _, a := range v.attrs {
+				out = be.AppendUint32(name(out, a.name), a.ncType)
+				if a.ncType == 2 {
+					out = pad(append(be.AppendUint32(out, uint32(len(a.text))), a.text...))
+				} else {
+					out = pad(append(be.AppendUint32(out, uint32(len(a.values))), encode(a.ncType, a.values)...))
+				}
+			}
+			out = be.AppendUint32(be.AppendUint32(be.AppendUint32(out, v.ncType), vsizes[i]), begins[i])
+		}
+		return out
+	}
+
+	begins, vsizes := make([]uint32, len(f.vars)), make([]uint32, len(f.vars))
+	for i, v := range f.vars {
+		vsizes[i] = uint32(len(pad(encode(v.ncType, make([]float64, sliceLen(v))))))
+	}
+	offset := uint32(len(header(begins, vsizes)))
+	for i, v := range f.vars {
+		if !isRecord(v) {
+			begins[i] = offset
+			offset += vsizes[i]
+		}
+	}
+	recordSize := uint32(0)
+	for i, v := range f.vars {
+		if isRecord(v) {
+			begins[i] = offset + recordSize
+			recordSize += vsizes[i]
+		}
+	}
+
+	out := header(begins, vsizes)
+	for _, v := range f.vars {
+		if !isRecord(v) {
+			out = append(out, pad(encode(v.ncType, v.data))...)
+		}
+	}
+	for r := 0; r < int(f.numRecs); r++ {
+		for _, v := range f.vars {
+			if isRecord(v) {
+				n := sliceLen(v)
+				out = append(out, pad(encode(v.ncType, v.data[r*n:(r+1)*n]))...)
+			}
+		}
+	}
+	return out
+}
+
+func coordinateVars(latDim, lonDim uint32) []testVar {
+	return []testVar{
+		{name: `lat`, dims: []uint32{latDim}, ncType: 5, data: []float64{40, 41},
+			attrs: []testAttr{{name: `units`, ncType: 2, text: `degrees_north`}}},
+		{name: `lon`, dims: []uint32{lonDim}, ncType: 5, data: []float64{-100, -99, -98},
+			attrs: []testAttr{{name: `units`, ncType: 2, text: `degrees_east`}}},
+	}
+}
+
+func TestReadPackedRecordVariable(t *testing.T) {
+	file := testFile{
+		dims:    []string{`time`, `lat`, `lon`},
+		lengths: []uint32{0, 2, 3},
+		numRecs: 2,
+		vars: append(coordinateVars(1, 2),
+			testVar{name: `t2m`, dims: []uint32{0, 1, 2}, ncType: 3,
+				attrs: []testAttr{
+					{name: `scale_factor`, ncType: 6, values: []float64{0.5}},
+					{name: `add_offset`, ncType: 6, values: []float64{200}},
+					{name: `_FillValue`, ncType: 3, values: []float64{-1}},
+				},
+				data: []float64{0, 1, 2, 3, 4, 5, 10, -1, 12, 13, 14, 15}},
+			testVar{name: `flag`, dims: []uint32{0, 1, 2}, ncType: 1,
+				data: []float64{1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2}},
+		),
+	}
+	grid, err := netcdf.Read(bytes.NewReader(file.build()), netcdf.Selection{Variable: `t2m`, Time: 1})
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if grid.SizeX != 3 || grid.SizeY != 2 {
+		t.Fatalf(`expected 3x2 grid, got %vx%v`, grid.SizeX, grid.SizeY)
+	}
+	assertValues(t, grid.Values, []float64{205, math.NaN(), 206, 206.5, 207, 207.5})
+	expected := grid_to_isobands.LatLonAxes{Lats: []float64{40, 41}, Lons: []float64{-100, -99, -98}}
+	if !reflect.DeepEqual(grid.Geometry, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, grid.Geometry)
+	}
+
+	flags, err := netcdf.Read(bytes.NewReader(file.build()), netcdf.Selection{Variable: `flag`, Time: 1})
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	assertValues(t, flags.Values, []float64{2, 2, 2, 2, 2, 2})
+}
+
+func TestReadUnsignedVariable(t *testing.T) {
+	file := testFile{
+		dims:    []string{`lat`, `lon`},
+		lengths: []uint32{2, 3},
+		vars: append(coordinateVars(0, 1),
+			testVar{name: `cover`, dims: []uint32{0, 1}, ncType: 1,
+				attrs: []testAttr{
+					{name: `_Unsigned`, ncType: 2, text: `true`},
+					{name: `_FillValue`, ncType: 1, values: []float64{-1}},
+					{name: `valid_max`, ncType: 1, values: []float64{-2}},
+				},
+				// -56, -1 and -2 are stored as 200, 255 and 254.
+				data: []float64{-56, -1, 10, -2, 0, 5}},
+		),
+	}
+	grid, err := netcdf.Read(bytes.NewReader(file.build()), netcdf.Selection{Variable: `cover`})
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	assertValues(t, grid.Values, []float64{200, math.NaN(), 10, 254, 0, 5})
+}
+
+func TestReadTransposedLevel(t *testing.T) {
+	file := testFile{
+		dims:    []string{`level`, `lat`, `lon`},
+		lengths: []uint32{2, 2, 3},
+		vars: append(coordinateVars(1, 2),
+			testVar{name: `z`, dims: []uint32{0, 2, 1}, ncType: 6,
+				data: []float64{0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6}},
+		),
+	}
+	grid, err := netcdf.Read(bytes.NewReader(file.build()), netcdf.Selection{Variable: `z`, Level: 1})
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if grid.SizeX != 3 || grid.SizeY != 2 {
+		t.Fatalf(`expected 3x2 grid, got %vx%v`, grid.SizeX, grid.SizeY)
+	}
+	assertValues(t, grid.Values, []float64{1, 3, 5, 2, 4, 6})
+
+	_, err = netcdf.Read(bytes.NewReader(file.build()), netcdf.Selection{Variable: `z`, Time: 1})
+	if err == nil {
+		t.Fatalf(`expected an error selecting a time on a variable without one`)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := netcdf.Read(bytes.NewReader([]byte("\x89HDF\r\n\x1a\n")), netcdf.Selection{Variable: `t2m`})
+	if !errors.Is(err, netcdf.ErrNetCDF4) {
+		t.Fatalf(`expected %v, got %v`, netcdf.ErrNetCDF4, err)
+	}
+	file := testFile{dims: []string{`lat`, `lon`}, lengths: []uint32{2, 3}, vars: coordinateVars(0, 1)}
+	_, err = netcdf.Read(bytes.NewReader(file.build()), netcdf.Selection{Variable: `t2m`})
+	if !errors.Is(err, netcdf.ErrVariableNotFound) {
+		t.Fatalf(`expected %v, got %v`, netcdf.ErrVariableNotFound, err)
+	}
+}
+
+func assertValues(t *testing.T, actual, expected []float64) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf(`expected %v values, got %v`, len(expected), len(actual))
+	}
+	for i := range expected {
+		if math.IsNaN(expected[i]) != math.IsNaN(actual[i]) || (!math.IsNaN(expected[i]) && expected[i] != actual[i]) {
+			t.Fatalf(`expected %v, got %v`, expected, actual)
+		}
+	}
+}
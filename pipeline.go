@@ -0,0 +1,350 @@
+package grid_to_isobands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pipeline is a serializable recipe for a chain of preprocesses, such as
+//
+//	[{"op": "gaussian", "kernel": 7, "sigma": 1}, {"op": "clip", "top": 20}]
+//
+// in JSON, or the same as a YAML sequence. Each step names a transformer
+// registered with RegisterTransformer, and Transformers builds the chain
+// from them. Unlike a []GridTransformer, a Pipeline can be stored in
+// config, logged and fingerprinted, and it marshals back to JSON or YAML
+// with the same steps.
+type Pipeline []Step
+
+// Step is one transformer of a Pipeline: Op is the name it is registered
+// under and Params the rest of its fields.
+type Step struct {
+	Op     string
+	Params map[string]any
+}
+
+// ParsePipeline reads a Pipeline from JSON or YAML.
+func ParsePipeline(data []byte) (Pipeline, error) {
+	var pipeline Pipeline
+	if err := yaml.Unmarshal(data, &pipeline); err != nil {
+		return nil, fmt.Errorf("error parsing pipeline: %w", err)
+	}
+	return pipeline, nil
+}
+
+// Transformers builds the steps of the pipeline into preprocesses, in order.
+func (p Pipeline) Transformers() ([]GridTransformer, error) {
+	built := make([]GridTransformer, len(p))
+	for i, step := range p {
+		transformer, err := step.Transformer()
+		if err != nil {
+			return nil, fmt.Errorf("error building pipeline step %d: %w", i, err)
+		}
+		built[i] = transformer
+	}
+	return built, nil
+}
+
+// Fingerprint is a hex SHA-256 hash of the pipeline's JSON, which steps
+// read from JSON and YAML share, for keying caches of its output.
+func (p Pipeline) Fingerprint() (string, error) {
+	encoded, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("error fingerprinting pipeline: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Transformer builds the step with the factory registered under its Op.
+// Parameters the factory doesn't read are reported as errors, so a typo
+// doesn't silently fall back to a default.
+func (s Step) Transformer() (GridTransformer, error) {
+	registry.RLock()
+	factory, ok := registry.factories[s.Op]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown transformer %q", s.Op)
+	}
+	params := &Params{values: s.Params, used: map[string]bool{}}
+	transformer, err := factory(params)
+	if err == nil {
+		err = params.err
+	}
+	if err == nil {
+		for _, name := range slices.Sorted(maps.Keys(s.Params)) {
+			if !params.used[name] {
+				err = fmt.Errorf("unknown parameter %q", name)
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error building %v: %w", s.Op, err)
+	}
+	return transformer, nil
+}
+
+// MarshalJSON writes the step as one object, with op first and the
+// parameters after it in name order.
+func (s Step) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	op, err := json.Marshal(s.Op)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(`{"op":`)
+	buf.Write(op)
+	for _, name := range slices.Sorted(maps.Keys(s.Params)) {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(s.Params[name])
+		if err != nil {
+			return nil, fmt.Errorf("error encoding parameter %v of %v: %w", name, s.Op, err)
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	return s.fromFields(fields)
+}
+
+// MarshalYAML writes the step as one mapping, with op first and the
+// parameters after it in name order.
+func (s Step) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(name string, value any) error {
+		var valueNode yaml.Node
+		if err := valueNode.Encode(value); err != nil {
+			return fmt.Errorf("error encoding parameter %v of %v: %w", name, s.Op, err)
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, &valueNode)
+		return nil
+	}
+	if err := add(`op`, s.Op); err != nil {
+		return nil, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Params)) {
+		if err := add(name, s.Params[name]); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (s *Step) UnmarshalYAML(node *yaml.Node) error {
+	var fields map[string]any
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	return s.fromFields(fields)
+}
+
+// fromFields splits the fields of a decoded step into its op and
+// parameters. YAML's .nan and .inf become the strings "NaN", "+Inf" and
+// "-Inf", which JSON can carry and Params reads back as numbers.
+func (s *Step) fromFields(fields map[string]any) error {
+	op, ok := fields[`op`].(string)
+	if !ok || op == "" {
+		return fmt.Errorf("pipeline step has no op: %v", fields)
+	}
+	s.Op = op
+	s.Params = nil
+	for name, value := range fields {
+		if name == `op` {
+			continue
+		}
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			value = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		if s.Params == nil {
+			s.Params = map[string]any{}
+		}
+		s.Params[name] = value
+	}
+	return nil
+}
+
+// TransformerFactory builds a GridTransformer from the parameters of a
+// pipeline step.
+type TransformerFactory func(params *Params) (GridTransformer, error)
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]TransformerFactory
+}{factories: map[string]TransformerFactory{}}
+
+// RegisterTransformer makes a transformer available to pipelines under name.
+// It panics if name is empty, factory is nil or name is already registered,
+// so clashes show up at startup.
+func RegisterTransformer(name string, factory TransformerFactory) {
+	if name == "" || factory == nil {
+		panic("grid_to_isobands: RegisterTransformer needs a name and a factory")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.factories[name]; ok {
+		panic("grid_to_isobands: transformer " + strconv.Quote(name) + " is already registered")
+	}
+	registry.factories[name] = factory
+}
+
+// RegisteredTransformers returns the names of every registered transformer
+// in order.
+func RegisteredTransformers() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Sorted(maps.Keys(registry.factories))
+}
+
+// Params are the parameters of a pipeline step. Its getters record the
+// first parameter that is missing or has the wrong type, which Err
+// returns, so a factory can read them all before checking once.
+type Params struct {
+	values map[string]any
+	used   map[string]bool
+	err    error
+}
+
+// Err returns the first problem the getters found.
+func (p *Params) Err() error {
+	return p.err
+}
+
+// Has reports whether the step sets the parameter.
+func (p *Params) Has(name string) bool {
+	_, ok := p.values[name]
+	return ok
+}
+
+func (p *Params) lookup(name string) (any, bool) {
+	value, ok := p.values[name]
+	if ok {
+		p.used[name] = true
+	}
+	return value, ok
+}
+
+func (p *Params) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+}
+
+// Float returns a required number parameter. It may also be given as a
+// string such as "NaN" or "-Inf".
+func (p *Params) Float(name string) float64 {
+	if !p.Has(name) {
+		p.fail("missing parameter %q", name)
+		return 0
+	}
+	return p.FloatOr(name, 0)
+}
+
+// FloatOr returns an optional number parameter, or fallback when it isn't
+// set.
+func (p *Params) FloatOr(name string, fallback float64) float64 {
+	value, ok := p.lookup(name)
+	if !ok {
+		return fallback
+	}
+	switch v := value.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	p.fail("parameter %q must be a number, got %v", name, value)
+	return fallback
+}
+
+// Int returns a required whole-number parameter.
+func (p *Params) Int(name string) int {
+	if !p.Has(name) {
+		p.fail("missing parameter %q", name)
+		return 0
+	}
+	return p.IntOr(name, 0)
+}
+
+// IntOr returns an optional whole-number parameter, or fallback when it
+// isn't set.
+func (p *Params) IntOr(name string, fallback int) int {
+	value, ok := p.lookup(name)
+	if !ok {
+		return fallback
+	}
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v)
+		}
+	}
+	p.fail("parameter %q must be a whole number, got %v", name, value)
+	return fallback
+}
+
+// String returns a required string parameter.
+func (p *Params) String(name string) string {
+	if !p.Has(name) {
+		p.fail("missing parameter %q", name)
+		return ""
+	}
+	return p.StringOr(name, "")
+}
+
+// StringOr returns an optional string parameter, or fallback when it isn't
+// set.
+func (p *Params) StringOr(name string, fallback string) string {
+	value, ok := p.lookup(name)
+	if !ok {
+		return fallback
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	p.fail("parameter %q must be a string, got %v", name, value)
+	return fallback
+}
+
+// BoolOr returns an optional true/false parameter, or fallback when it
+// isn't set.
+func (p *Params) BoolOr(name string, fallback bool) bool {
+	value, ok := p.lookup(name)
+	if !ok {
+		return fallback
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	p.fail("parameter %q must be true or false, got %v", name, value)
+	return fallback
+}
@@ -0,0 +1,191 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+	"github.com/skysparq/grid-to-isobands/transformers"
+	"gopkg.in/yaml.v3"
+)
+
+const pipelineJSON = `[
+	{"op": "unit", "from": "K", "to": "degC"},
+	{"op": "gaussian", "kernel": 3, "sigma": 1, "boundary": "reflect"},
+	{"op": "threshold-mask", "lessThan": -40, "replacement": "NaN"},
+	{"op": "clip", "top": 1}
+]`
+
+const pipelineYAML = `
+- op: unit
+  from: K
+  to: degC
+- {op: gaussian, kernel: 3, sigma: 1.0, boundary: reflect}
+- op: threshold-mask
+  lessThan: -40
+  replacement: .nan
+- op: clip
+  top: 1
+`
+
+func pipelineGrid() *grid_to_isobands.GridValues {
+	values := &grid_to_isobands.GridValues{
+		SizeX:    6,
+		SizeY:    5,
+		Values:   make([]float64, 30),
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 50, Lon0: 0, DLat: -1, DLon: 1},
+	}
+	for i := range values.Values {
+		values.Values[i] = 220 + 3*float64(i)
+	}
+	return values
+}
+
+func TestPipelineMatchesTransformers(t *testing.T) {
+	expected := pipelineGrid()
+	for _, transform := range []grid_to_isobands.GridTransformer{
+		grid_to_isobands.UnitTransformer(grid_to_isobands.Kelvin, grid_to_isobands.Celsius),
+		grid_to_isobands.GaussianTransformer(3, 1, transformers.Options{Boundary: transformers.BoundaryReflect}),
+		grid_to_isobands.ThresholdMaskTransformer(transformers.LessThan(-40), math.NaN()),
+		grid_to_isobands.ClipTransformer(transformers.Clip{Top: 1}),
+	} {
+		if err := transform(context.Background(), expected); err != nil {
+			t.Fatalf(`expected no error, got %v`, err)
+		}
+	}
+	for name, spec := range map[string]string{`json`: pipelineJSON, `yaml`: pipelineYAML} {
+		t.Run(name, func(t *testing.T) {
+			pipeline, err := grid_to_isobands.ParsePipeline([]byte(spec))
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			steps, err := pipeline.Transformers()
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			actual := pipelineGrid()
+			for _, step := range steps {
+				if err := step(context.Background(), actual); err != nil {
+					t.Fatalf(`expected no error, got %v`, err)
+				}
+			}
+			assertGridValues(t, actual.Values, expected.Values)
+		})
+	}
+}
+
+func assertGridValues(t *testing.T, actual, expected []float64) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf(`expected %v, got %v`, expected, actual)
+	}
+	for i := range expected {
+		if math.IsNaN(expected[i]) != math.IsNaN(actual[i]) || (!math.IsNaN(expected[i]) && math.Abs(expected[i]-actual[i]) > 1e-9) {
+			t.Fatalf(`expected %v, got %v`, expected, actual)
+		}
+	}
+}
+
+func TestPipelineRoundTrips(t *testing.T) {
+	fromJSON, err := grid_to_isobands.ParsePipeline([]byte(pipelineJSON))
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	fromYAML, err := grid_to_isobands.ParsePipeline([]byte(pipelineYAML))
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	fingerprint, err := fromJSON.Fingerprint()
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if other, _ := fromYAML.Fingerprint(); other != fingerprint {
+		t.Fatalf(`expected %v, got %v`, fingerprint, other)
+	}
+
+	encoded, err := json.Marshal(fromYAML)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	expected := `[{"op":"unit","from":"K","to":"degC"},` +
+		`{"op":"gaussian","boundary":"reflect","kernel":3,"sigma":1},` +
+		`{"op":"threshold-mask","lessThan":-40,"replacement":"NaN"},` +
+		`{"op":"clip","top":1}]`
+	if string(encoded) != expected {
+		t.Fatalf(`expected %v, got %v`, expected, string(encoded))
+	}
+
+	encoded, err = yaml.Marshal(fromJSON)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if !strings.HasPrefix(string(encoded), "- op: unit\n  from: K\n") {
+		t.Fatalf(`expected op first, got %v`, string(encoded))
+	}
+	reparsed, err := grid_to_isobands.ParsePipeline(encoded)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if other, _ := reparsed.Fingerprint(); other != fingerprint {
+		t.Fatalf(`expected %v, got %v`, fingerprint, other)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	for _, spec := range []string{
+		`[{"kernel": 3}]`,
+		`[{"op": "no-such-transformer"}]`,
+		`[{"op": "gaussian", "kernel": 3}]`,
+		`[{"op": "gaussian", "kernel": 3, "sigma": 1, "sigmaa": 2}]`,
+		`[{"op": "gaussian", "kernel": 3.5, "sigma": 1}]`,
+		`[{"op": "median", "kernel": 3, "boundary": "mirror"}]`,
+		`[{"op": "threshold-mask", "lessThan": 1, "greaterThan": 2}]`,
+	} {
+		pipeline, err := grid_to_isobands.ParsePipeline([]byte(spec))
+		if err == nil {
+			_, err = pipeline.Transformers()
+		}
+		if err == nil {
+			t.Fatalf(`expected an error for %v, got nil`, spec)
+		}
+	}
+}
+
+func TestRegisterTransformer(t *testing.T) {
+	grid_to_isobands.RegisterTransformer(`test-offset`, func(p *grid_to_isobands.Params) (grid_to_isobands.GridTransformer, error) {
+		offset := p.Float(`by`)
+		return grid_to_isobands.TransformerFromFunc(func(values *grid_to_isobands.GridValues) {
+			for i := range values.Values {
+				values.Values[i] += offset
+			}
+		}), nil
+	})
+	pipeline, err := grid_to_isobands.ParsePipeline([]byte(`[{"op": "test-offset", "by": 2}]`))
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	steps, err := pipeline.Transformers()
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	values := &grid_to_isobands.GridValues{SizeX: 2, SizeY: 1, Values: []float64{1, 2}}
+	if err := steps[0](context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if expected := []float64{3, 4}; !reflect.DeepEqual(values.Values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, values.Values)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf(`expected registering a name twice to panic`)
+		}
+	}()
+	grid_to_isobands.RegisterTransformer(`gaussian`, func(p *grid_to_isobands.Params) (grid_to_isobands.GridTransformer, error) {
+		return nil, nil
+	})
+}
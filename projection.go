@@ -0,0 +1,284 @@
+package grid_to_isobands
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// EarthRadius is the spherical earth radius, in meters, used by GRIB2 shape of
+// earth code 6 and by most NCEP projected grids (HRRR, NAM).
+const EarthRadius = 6371229.0
+
+// Projection converts between geographic coordinates, in degrees, and a map
+// projection's native x/y plane, in meters. All projections here use a
+// spherical earth, matching the GRIB2 grids they describe; a zero Radius means
+// EarthRadius.
+type Projection interface {
+	Forward(lat, lon float64) (x, y float64)
+	Inverse(x, y float64) (lat, lon float64)
+}
+
+// preparer is implemented by projections with constants that are worth
+// working out once before projecting many points.
+type preparer interface {
+	prepare() Projection
+}
+
+// prepare returns p ready for projecting many points.
+func prepare(p Projection) Projection {
+	if p, ok := p.(preparer); ok {
+		return p.prepare()
+	}
+	return p
+}
+
+// prepareGeometry returns geometry with its projection, if it has one, ready
+// for finding many points.
+func prepareGeometry(geometry GridGeometry) GridGeometry {
+	if g, ok := geometry.(ProjectedGrid); ok {
+		g.Projection = prepare(g.Projection)
+		return g
+	}
+	return geometry
+}
+
+// LambertConformal is a Lambert conformal conic projection with standard
+// parallels Lat1 and Lat2 (equal for a tangent cone), central meridian Lon0,
+// and y measured from latitude Lat0.
+type LambertConformal struct {
+	Lat1   float64
+	Lat2   float64
+	Lat0   float64
+	Lon0   float64
+	Radius float64
+}
+
+// lambertConformal is a LambertConformal with the constants of its cone
+// worked out, which its Forward and Inverse would otherwise redo, logs,
+// tangents and powers included, for every point.
+type lambertConformal struct {
+	LambertConformal
+	n, rf, rho0 float64
+}
+
+func (p LambertConformal) prepared() lambertConformal {
+	phi1, phi2 := radians(p.Lat1), radians(p.Lat2)
+	var n float64
+	if math.Abs(phi1-phi2) < 1e-10 {
+		n = math.Sin(phi1)
+	} else {
+		n = math.Log(math.Cos(phi1)/math.Cos(phi2)) /
+			math.Log(math.Tan(math.Pi/4+phi2/2)/math.Tan(math.Pi/4+phi1/2))
+	}
+	rf := radius(p.Radius) * math.Cos(phi1) * math.Pow(math.Tan(math.Pi/4+phi1/2), n) / n
+	rho0 := rf / math.Pow(math.Tan(math.Pi/4+radians(p.Lat0)/2), n)
+	return lambertConformal{LambertConformal: p, n: n, rf: rf, rho0: rho0}
+}
+
+func (p LambertConformal) prepare() Projection {
+	return p.prepared()
+}
+
+func (p LambertConformal) Forward(lat, lon float64) (float64, float64) {
+	return p.prepared().Forward(lat, lon)
+}
+
+func (p LambertConformal) Inverse(x, y float64) (float64, float64) {
+	return p.prepared().Inverse(x, y)
+}
+
+func (p lambertConformal) Forward(lat, lon float64) (float64, float64) {
+	rho := 0.0
+	if math.Abs(lat) < 90 {
+		rho = p.rf / math.Pow(math.Tan(math.Pi/4+radians(lat)/2), p.n)
+	}
+	theta := p.n * radians(normalizeLon(lon-p.Lon0))
+	return rho * math.Sin(theta), p.rho0 - rho*math.Cos(theta)
+}
+
+func (p lambertConformal) Inverse(x, y float64) (float64, float64) {
+	dy := p.rho0 - y
+	rho := math.Copysign(math.Hypot(x, dy), p.n)
+	if rho == 0 {
+		return math.Copysign(90, p.n), p.Lon0
+	}
+	theta := math.Atan2(x, dy)
+	if p.n < 0 {
+		theta = math.Atan2(-x, -dy)
+	}
+	lat := 2*math.Atan(math.Pow(p.rf/rho, 1/p.n)) - math.Pi/2
+	return degrees(lat), normalizeLon(degrees(theta/p.n) + p.Lon0)
+}
+
+// PolarStereographic is a polar stereographic projection centered on the
+// north pole (or the south pole when South is set), true to scale at latitude
+// LatTs, with Lon0 pointing straight down (north) or up (south) from the pole.
+type PolarStereographic struct {
+	LatTs  float64
+	Lon0   float64
+	South  bool
+	Radius float64
+}
+
+func (p PolarStereographic) scale() float64 {
+	k := (1 + math.Sin(radians(math.Abs(p.LatTs)))) / 2
+	return 2 * radius(p.Radius) * k
+}
+
+func (p PolarStereographic) Forward(lat, lon float64) (float64, float64) {
+	lambda := radians(normalizeLon(lon - p.Lon0))
+	if p.South {
+		rho := p.scale() * math.Tan(math.Pi/4+radians(lat)/2)
+		return rho * math.Sin(lambda), rho * math.Cos(lambda)
+	}
+	rho := p.scale() * math.Tan(math.Pi/4-radians(lat)/2)
+	return rho * math.Sin(lambda), -rho * math.Cos(lambda)
+}
+
+func (p PolarStereographic) Inverse(x, y float64) (float64, float64) {
+	c := 2 * math.Atan(math.Hypot(x, y)/p.scale())
+	if p.South {
+		return degrees(c - math.Pi/2), normalizeLon(p.Lon0 + degrees(math.Atan2(x, y)))
+	}
+	return degrees(math.Pi/2 - c), normalizeLon(p.Lon0 + degrees(math.Atan2(x, -y)))
+}
+
+// Mercator is a normal-aspect Mercator projection true to scale at latitude
+// LatTs, with x measured from meridian Lon0.
+type Mercator struct {
+	LatTs  float64
+	Lon0   float64
+	Radius float64
+}
+
+func (p Mercator) Forward(lat, lon float64) (float64, float64) {
+	r := radius(p.Radius) * math.Cos(radians(p.LatTs))
+	return r * radians(normalizeLon(lon-p.Lon0)), r * math.Log(math.Tan(math.Pi/4+radians(lat)/2))
+}
+
+func (p Mercator) Inverse(x, y float64) (float64, float64) {
+	r := radius(p.Radius) * math.Cos(radians(p.LatTs))
+	lat := 2*math.Atan(math.Exp(y/r)) - math.Pi/2
+	return degrees(lat), normalizeLon(degrees(x/r) + p.Lon0)
+}
+
+// ProjectedGrid is a grid evenly spaced in a projection's x/y plane. X0 and Y0
+// are the projected coordinates of the first point (column 0, row 0); DX and
+// DY are the signed steps between columns and rows, in meters. Isobands for a
+// ProjectedGrid are contoured in x/y and reprojected to lon/lat afterwards.
+type ProjectedGrid struct {
+	Projection Projection
+	X0         float64
+	Y0         float64
+	DX         float64
+	DY         float64
+	// MaxSegment is the longest ring edge, in meters, reprojected without
+	// inserting intermediate points. Zero means the smaller of |DX| and |DY|.
+	MaxSegment float64
+}
+
+// NewProjectedGrid returns the grid whose first point is at lat1/lon1, the
+// way GRIB2 grid definitions describe projected grids.
+func NewProjectedGrid(projection Projection, lat1, lon1, dx, dy float64) ProjectedGrid {
+	x0, y0 := projection.Forward(lat1, lon1)
+	return ProjectedGrid{Projection: projection, X0: x0, Y0: y0, DX: dx, DY: dy}
+}
+
+// XY returns the projected coordinates of the point at column x, row y.
+func (g ProjectedGrid) XY(x, y int) (float64, float64) {
+	return g.X0 + float64(x)*g.DX, g.Y0 + float64(y)*g.DY
+}
+
+func (g ProjectedGrid) LatLon(x, y int) (float64, float64) {
+	return g.Projection.Inverse(g.XY(x, y))
+}
+
+func (g ProjectedGrid) maxSegment() float64 {
+	if g.MaxSegment > 0 {
+		return g.MaxSegment
+	}
+	return min(math.Abs(g.DX), math.Abs(g.DY))
+}
+
+// axes returns the projected x and y coordinates of the grid's columns and
+// rows.
+func (g ProjectedGrid) axes(sizeX, sizeY int) (xs, ys []float64) {
+	xs, ys = make([]float64, sizeX), make([]float64, sizeY)
+	for x := range xs {
+		xs[x], _ = g.XY(x, 0)
+	}
+	for y := range ys {
+		_, ys[y] = g.XY(0, y)
+	}
+	return xs, ys
+}
+
+// toLonLat reprojects a ring from the grid's x/y plane to lon/lat, first
+// splitting edges longer than MaxSegment so curved parallels and meridians
+// stay accurate.
+func (g ProjectedGrid) toLonLat(ring orb.Ring) orb.Ring {
+	dense := densify(ring, g.maxSegment())
+	projection := prepare(g.Projection)
+	for i, point := range dense {
+		lat, lon := projection.Inverse(point[0], point[1])
+		dense[i] = orb.Point{lon, lat}
+	}
+	return dense
+}
+
+// fromLonLat projects a lon/lat ring into the grid's x/y plane, densifying it
+// in lon/lat first at roughly MaxSegment spacing.
+func (g ProjectedGrid) fromLonLat(ring orb.Ring) orb.Ring {
+	dense := densify(ring, g.maxSegment()/metersPerDegree)
+	projection := prepare(g.Projection)
+	for i, point := range dense {
+		dense[i][0], dense[i][1] = projection.Forward(point.Lat(), point.Lon())
+	}
+	return dense
+}
+
+const metersPerDegree = math.Pi * EarthRadius / 180
+
+// densify returns a copy of ring with extra points inserted so no edge is
+// longer than maxSegment.
+func densify(ring orb.Ring, maxSegment float64) orb.Ring {
+	if maxSegment <= 0 || len(ring) < 2 {
+		return append(orb.Ring(nil), ring...)
+	}
+	dense := make(orb.Ring, 0, len(ring))
+	for i := 0; i < len(ring)-1; i++ {
+		a, b := ring[i], ring[i+1]
+		dense = append(dense, a)
+		steps := int(math.Ceil(math.Hypot(b[0]-a[0], b[1]-a[1]) / maxSegment))
+		for s := 1; s < steps; s++ {
+			f := float64(s) / float64(steps)
+			dense = append(dense, orb.Point{a[0] + f*(b[0]-a[0]), a[1] + f*(b[1]-a[1])})
+		}
+	}
+	return append(dense, ring[len(ring)-1])
+}
+
+func radius(r float64) float64 {
+	if r == 0 {
+		return EarthRadius
+	}
+	return r
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// normalizeLon wraps a longitude into [-180, 180).
+func normalizeLon(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
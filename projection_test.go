@@ -0,0 +1,79 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/skysparq/grid-to-isobands"
+)
+
+func TestProjectionsRoundTrip(t *testing.T) {
+	projections := map[string]grid_to_isobands.Projection{
+		`lambert`:      grid_to_isobands.LambertConformal{Lat1: 25, Lat2: 45, Lat0: 38.5, Lon0: -97.5},
+		`lambert-sp`:   grid_to_isobands.LambertConformal{Lat1: -30, Lat2: -30, Lon0: 140},
+		`polar-north`:  grid_to_isobands.PolarStereographic{LatTs: 60, Lon0: -105},
+		`polar-south`:  grid_to_isobands.PolarStereographic{LatTs: -71, Lon0: 0, South: true},
+		`mercator`:     grid_to_isobands.Mercator{LatTs: 20, Lon0: -160},
+		`mercator-eqt`: grid_to_isobands.Mercator{},
+	}
+	points := [][2]float64{{35, -100}, {50, -75}, {20, -120}}
+	for name, projection := range projections {
+		for _, point := range points {
+			lat, lon := point[0], point[1]
+			if name == `polar-south` || name == `lambert-sp` {
+				lat = -lat
+			}
+			x, y := projection.Forward(lat, lon)
+			gotLat, gotLon := projection.Inverse(x, y)
+			if math.Abs(gotLat-lat) > 1e-9 || math.Abs(gotLon-lon) > 1e-9 {
+				t.Fatalf(`%v: expected %v,%v, got %v,%v`, name, lat, lon, gotLat, gotLon)
+			}
+		}
+	}
+}
+
+func TestHrrrProjectedGrid(t *testing.T) {
+	projection := grid_to_isobands.LambertConformal{Lat1: 38.5, Lat2: 38.5, Lat0: 38.5, Lon0: 262.5}
+	grid := grid_to_isobands.NewProjectedGrid(projection, 21.138123, 237.280472, 3000, 3000)
+	lat, lon := grid.LatLon(1798, 1058)
+	if math.Abs(lat-47.842195) > 1e-3 || math.Abs(lon+60.917193) > 1e-3 {
+		t.Fatalf(`expected HRRR north-east corner near 47.842,-60.917, got %v,%v`, lat, lon)
+	}
+}
+
+func TestProjectedGeometryTransformsMatchExpanded(t *testing.T) {
+	projection := grid_to_isobands.LambertConformal{Lat1: 38.5, Lat2: 38.5, Lat0: 38.5, Lon0: -97.5}
+	geometry := grid_to_isobands.NewProjectedGrid(projection, 35, -100, 3000, 3000)
+	transforms := map[string]grid_to_isobands.GridTransformer{
+		`reverse`: grid_to_isobands.ReverseVerticalTransformer(),
+		`crop`:    grid_to_isobands.CropTransformer(orb.Bound{Min: orb.Point{-99.99, 35.02}, Max: orb.Point{-99, 36}}),
+	}
+	for name, transform := range transforms {
+		compact := &grid_to_isobands.GridValues{
+			SizeX:    5,
+			SizeY:    4,
+			Values:   make([]float64, 20),
+			Geometry: geometry,
+		}
+		expanded := &grid_to_isobands.GridValues{
+			SizeX:    5,
+			SizeY:    4,
+			Values:   make([]float64, 20),
+			Geometry: geometry,
+		}
+		expanded.ExpandCoordinates()
+		transform(context.Background(), compact)
+		transform(context.Background(), expanded)
+		compact.ExpandCoordinates()
+		if compact.SizeX != expanded.SizeX || compact.SizeY != expanded.SizeY {
+			t.Fatalf(`%v: expected %vx%v, got %vx%v`, name, expanded.SizeX, expanded.SizeY, compact.SizeX, compact.SizeY)
+		}
+		for i := range compact.Lats {
+			if math.Abs(compact.Lats[i]-expanded.Lats[i]) > 1e-9 || math.Abs(compact.Lons[i]-expanded.Lons[i]) > 1e-9 {
+				t.Fatalf(`%v: expected %v,%v at %v, got %v,%v`, name, expanded.Lats[i], expanded.Lons[i], i, compact.Lats[i], compact.Lons[i])
+			}
+		}
+	}
+}
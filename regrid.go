@@ -0,0 +1,589 @@
+package grid_to_isobands
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+)
+
+// GridDefinition is where the points of a grid are, without its values: the
+// SizeX, SizeY, Lats, Lons and Geometry fields of a GridValues.
+type GridDefinition struct {
+	SizeX    int
+	SizeY    int
+	Lats     []float64
+	Lons     []float64
+	Geometry GridGeometry
+}
+
+// Definition returns where the points of the grid are.
+func (g *GridValues) Definition() GridDefinition {
+	return GridDefinition{SizeX: g.SizeX, SizeY: g.SizeY, Lats: g.Lats, Lons: g.Lons, Geometry: g.Geometry}
+}
+
+// grid returns a GridValues of zeros on the definition, for validating it and
+// reading its coordinates.
+func (d GridDefinition) grid() *GridValues {
+	size := 0
+	if d.SizeX > 0 && d.SizeY > 0 && d.SizeX <= math.MaxInt/d.SizeY {
+		size = d.SizeX * d.SizeY
+	}
+	return &GridValues{SizeX: d.SizeX, SizeY: d.SizeY, Values: make([]float64, size), Lats: d.Lats, Lons: d.Lons, Geometry: d.Geometry}
+}
+
+// RegridMethod is how a Regridder estimates the value at a target point.
+type RegridMethod int
+
+const (
+	// RegridNearest takes the value of the nearest source point. It suits
+	// categorical fields such as precipitation type.
+	RegridNearest RegridMethod = iota
+	// RegridBilinear blends the four source points around the target point.
+	RegridBilinear
+	// RegridConservative averages the source cells a target cell overlaps,
+	// weighted by the area on the sphere they share, so the total over an
+	// area (the sum of values times cell areas) is kept. Cells reach halfway
+	// to the neighboring points and half a step beyond the edge ones. Both
+	// grids must have lat/lon axes: a RegularLatLon, a LatLonAxes, or
+	// per-point coordinates that CompactCoordinates can turn into axes.
+	RegridConservative
+	// RegridAreaAverage averages the source cells a target cell covers,
+	// found by sampling it on an even lattice with at least two samples per
+	// source cell along each axis. It works between any grids NewRegridder
+	// accepts, but the weights are approximate and ignore how cell areas
+	// vary, so unlike RegridConservative it doesn't keep totals. Building
+	// the weights costs time in proportion to the number of source cells the
+	// target grid covers.
+	RegridAreaAverage
+)
+
+func (m RegridMethod) String() string {
+	switch m {
+	case RegridNearest:
+		return "nearest"
+	case RegridBilinear:
+		return "bilinear"
+	case RegridConservative:
+		return "conservative"
+	case RegridAreaAverage:
+		return "area-average"
+	default:
+		return fmt.Sprintf("RegridMethod(%d)", int(m))
+	}
+}
+
+// Regridder maps values from one grid onto another. Building one works out,
+// for every target point, which source points it reads and with what
+// weights; that is the expensive part, so a Regridder built once for a
+// product can regrid every frame of it.
+type Regridder struct {
+	source GridDefinition
+	target GridDefinition
+	// The weights of target point t are weights[offsets[t]:offsets[t+1]],
+	// applied to the source points at the same indices of sources.
+	offsets []int
+	sources []int32
+	weights []float64
+}
+
+// NewRegridder works out the weights mapping values on source onto target.
+// The source must be a RegularLatLon, LatLonAxes or ProjectedGrid, or have
+// per-point coordinates that CompactCoordinates can turn into axes; the
+// target can be any valid grid. Target points beyond the edges of the source
+// get NaN. RegridConservative needs lat/lon axes on both grids. It stops with
+// ctx.Err() if ctx is cancelled.
+func NewRegridder(ctx context.Context, source, target GridDefinition, method RegridMethod) (*Regridder, error) {
+	if method < RegridNearest || method > RegridAreaAverage {
+		return nil, fmt.Errorf("unknown regrid method %v", method)
+	}
+	if err := source.grid().Validate(); err != nil {
+		return nil, fmt.Errorf("error in regrid source: %w", err)
+	}
+	targetGrid := target.grid()
+	if err := targetGrid.Validate(); err != nil {
+		return nil, fmt.Errorf("error in regrid target: %w", err)
+	}
+	size := target.SizeX * target.SizeY
+	r := &Regridder{source: source, target: target, offsets: make([]int, 0, size+1)}
+	r.offsets = append(r.offsets, 0)
+	if method == RegridConservative {
+		if err := r.conservative(ctx); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	locate, err := newLocator(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find every target point in the source grid's index space.
+	xs, ys := make([]float64, size), make([]float64, size)
+	latLon := targetGrid.latLons()
+	for y := 0; y < target.SizeY; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for x := 0; x < target.SizeX; x++ {
+			i := y*target.SizeX + x
+			xs[i], ys[i] = locate.position(latLon(x, y))
+		}
+	}
+
+	switch method {
+	case RegridNearest, RegridBilinear:
+		for i := range xs {
+			if i%target.SizeX == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+			if method == RegridNearest {
+				r.nearest(locate, xs[i], ys[i])
+			} else {
+				r.bilinear(locate, xs[i], ys[i])
+			}
+			r.offsets = append(r.offsets, len(r.sources))
+		}
+	case RegridAreaAverage:
+		if err := r.areaAverage(ctx, locate, xs, ys); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Source returns the grid the Regridder maps values from.
+func (r *Regridder) Source() GridDefinition {
+	return r.source
+}
+
+// Target returns the grid the Regridder maps values onto.
+func (r *Regridder) Target() GridDefinition {
+	return r.target
+}
+
+func (r *Regridder) add(source int, weight float64) {
+	if weight == 0 {
+		return
+	}
+	r.sources = append(r.sources, int32(source))
+	r.weights = append(r.weights, weight)
+}
+
+func (r *Regridder) nearest(locate locator, x, y float64) {
+	if source, ok := locate.cell(x, y); ok {
+		r.add(source, 1)
+	}
+}
+
+func (r *Regridder) bilinear(locate locator, x, y float64) {
+	if _, ok := locate.cell(x, y); !ok {
+		return
+	}
+	if !locate.periodic {
+		x = min(max(x, 0), float64(locate.sizeX-1))
+	}
+	y = min(max(y, 0), float64(locate.sizeY-1))
+	x0, y0 := math.Floor(x), math.Floor(y)
+	tx, ty := x-x0, y-y0
+	for _, corner := range [4]struct{ dx, dy, w float64 }{
+		{0, 0, (1 - tx) * (1 - ty)},
+		{1, 0, tx * (1 - ty)},
+		{0, 1, (1 - tx) * ty},
+		{1, 1, tx * ty},
+	} {
+		if source, ok := locate.cell(x0+corner.dx, y0+corner.dy); ok {
+			r.add(source, corner.w)
+		}
+	}
+}
+
+// conservative weights each source cell by the area it shares with each
+// target cell, as a share of the target cell's area. Cells on lat/lon axes
+// are bounded by two latitudes and two longitudes, so their overlaps are
+// found separately along each axis: the area between latitudes is
+// proportional to the difference of their sines, and between longitudes to
+// the difference of the longitudes.
+func (r *Regridder) conservative(ctx context.Context) error {
+	source, ok := r.source.latLonAxes()
+	if !ok {
+		return fmt.Errorf("can't regrid conservatively from a grid without lat/lon axes")
+	}
+	target, ok := r.target.latLonAxes()
+	if !ok {
+		return fmt.Errorf("can't regrid conservatively onto a grid without lat/lon axes")
+	}
+	sourceLats, targetLats := sineCells(source.Lats), sineCells(target.Lats)
+	sourceLons := axisCells(unwrapLons(source.Lons, len(source.Lons), 1))
+	targetLons := axisCells(unwrapLons(target.Lons, len(target.Lons), 1))
+	rows := overlaps(targetLats, sourceLats, 0)
+	columns := overlaps(targetLons, sourceLons, 360)
+
+	for y, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		height := targetLats[y][1] - targetLats[y][0]
+		for x, column := range columns {
+			area := height * (targetLons[x][1] - targetLons[x][0])
+			if area > 0 {
+				for _, sy := range row {
+					for _, sx := range column {
+						r.add(sy.index*r.source.SizeX+sx.index, sy.size*sx.size/area)
+					}
+				}
+			}
+			r.offsets = append(r.offsets, len(r.sources))
+		}
+	}
+	return nil
+}
+
+// latLonAxes returns the axes of a grid whose points lie on lat/lon axes,
+// and false for any other grid.
+func (d GridDefinition) latLonAxes() (LatLonAxes, bool) {
+	grid := d.grid()
+	if !grid.CompactCoordinates() {
+		return LatLonAxes{}, false
+	}
+	return toAxes(grid.Geometry, d.SizeX, d.SizeY)
+}
+
+// axisCells returns the interval around each point of an axis, reaching
+// halfway to its neighbors and half a step beyond the end points, lowest
+// bound first. An axis of one point counts as a step of one degree.
+func axisCells(axis []float64) [][2]float64 {
+	n := len(axis)
+	edge := func(i int) float64 {
+		switch {
+		case n == 1:
+			return axis[0] + float64(i) - 0.5
+		case i == 0:
+			return 1.5*axis[0] - 0.5*axis[1]
+		case i == n:
+			return 1.5*axis[n-1] - 0.5*axis[n-2]
+		default:
+			return (axis[i-1] + axis[i]) / 2
+		}
+	}
+	cells := make([][2]float64, n)
+	for i := range cells {
+		a, b := edge(i), edge(i+1)
+		cells[i] = [2]float64{min(a, b), max(a, b)}
+	}
+	return cells
+}
+
+// sineCells returns the cells of a latitude axis as the sines of their
+// bounds, kept within the poles.
+func sineCells(lats []float64) [][2]float64 {
+	cells := axisCells(lats)
+	for i, cell := range cells {
+		for k, lat := range cell {
+			cells[i][k] = math.Sin(min(max(lat, -90), 90) * math.Pi / 180)
+		}
+	}
+	return cells
+}
+
+// overlap is how much of source interval index a target interval shares.
+type overlap struct {
+	index int
+	size  float64
+}
+
+// overlaps lists, for every target interval, the source intervals it
+// shares any of and how much. A period other than zero says the intervals
+// repeat every period, as longitudes do.
+func overlaps(targets, sources [][2]float64, period float64) [][]overlap {
+	result := make([][]overlap, len(targets))
+	for t, target := range targets {
+		for s, source := range sources {
+			shifts := []float64{0}
+			if period > 0 {
+				shift := period * math.Round((target[0]+target[1]-source[0]-source[1])/2/period)
+				shifts = []float64{shift - period, shift, shift + period}
+			}
+			size := 0.0
+			for _, shift := range shifts {
+				size += max(min(target[1], source[1]+shift)-max(target[0], source[0]+shift), 0)
+			}
+			if size > 0 {
+				result[t] = append(result[t], overlap{index: s, size: size})
+			}
+		}
+	}
+	return result
+}
+
+// areaAverage samples every target cell on a lattice spanning it in the
+// source index space, weighting each source cell by the share of samples
+// landing in it. The corners of target cells lie halfway between target
+// points, extrapolated at the edges.
+func (r *Regridder) areaAverage(ctx context.Context, locate locator, xs, ys []float64) error {
+	width, height := r.target.SizeX, r.target.SizeY
+	if locate.periodic {
+		unwrapPositions(xs, width, height, float64(locate.sizeX))
+	}
+	cornerXs := cellCorners(xs, width, height)
+	cornerYs := cellCorners(ys, width, height)
+	corner := func(corners []float64, x, y int) float64 {
+		return corners[y*(width+1)+x]
+	}
+
+	counts := map[int]int{}
+	for y := 0; y < height; y++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for x := 0; x < width; x++ {
+			var quad [4][2]float64
+			extent := 0.0
+			for k, c := range [4][2]int{{x, y}, {x + 1, y}, {x, y + 1}, {x + 1, y + 1}} {
+				quad[k] = [2]float64{corner(cornerXs, c[0], c[1]), corner(cornerYs, c[0], c[1])}
+			}
+			for _, edge := range [4][2]int{{0, 1}, {0, 2}, {1, 3}, {2, 3}} {
+				a, b := quad[edge[0]], quad[edge[1]]
+				extent = max(extent, math.Abs(a[0]-b[0]), math.Abs(a[1]-b[1]))
+			}
+			if math.IsNaN(extent) {
+				r.offsets = append(r.offsets, len(r.sources))
+				continue
+			}
+			n := max(int(math.Ceil(2*extent)), 1)
+			clear(counts)
+			for v := 0; v < n; v++ {
+				fv := (float64(v) + 0.5) / float64(n)
+				for u := 0; u < n; u++ {
+					fu := (float64(u) + 0.5) / float64(n)
+					sx := (1-fu)*(1-fv)*quad[0][0] + fu*(1-fv)*quad[1][0] + (1-fu)*fv*quad[2][0] + fu*fv*quad[3][0]
+					sy := (1-fu)*(1-fv)*quad[0][1] + fu*(1-fv)*quad[1][1] + (1-fu)*fv*quad[2][1] + fu*fv*quad[3][1]
+					if source, ok := locate.cell(sx, sy); ok {
+						counts[source]++
+					}
+				}
+			}
+			for _, source := range slices.Sorted(maps.Keys(counts)) {
+				r.add(source, float64(counts[source])/float64(n*n))
+			}
+			r.offsets = append(r.offsets, len(r.sources))
+		}
+	}
+	return nil
+}
+
+// unwrapPositions shifts the source columns found for each target row by
+// multiples of period so neighbors never differ by more than half of it,
+// keeping target cells that straddle the seam of a global source grid in one
+// piece.
+func unwrapPositions(xs []float64, width, height int, period float64) {
+	for y := 0; y < height; y++ {
+		row := xs[y*width : (y+1)*width]
+		for x := 1; x < len(row); x++ {
+			if !math.IsNaN(row[x-1]) {
+				row[x] = row[x-1] + math.Remainder(row[x]-row[x-1], period)
+			}
+		}
+	}
+}
+
+// cellCorners returns the (width+1)x(height+1) corners of the cells around a
+// grid of width x height points, halfway between neighboring points and
+// extrapolated by half a step beyond the edge ones.
+func cellCorners(points []float64, width, height int) []float64 {
+	along := func(line func(i int) float64, n, i int) float64 {
+		switch {
+		case n == 1:
+			return line(0) + float64(i) - 0.5
+		case i == 0:
+			return 1.5*line(0) - 0.5*line(1)
+		case i == n:
+			return 1.5*line(n-1) - 0.5*line(n-2)
+		default:
+			return (line(i-1) + line(i)) / 2
+		}
+	}
+	rows := make([]float64, (width+1)*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x <= width; x++ {
+			rows[y*(width+1)+x] = along(func(i int) float64 { return points[y*width+i] }, width, x)
+		}
+	}
+	corners := make([]float64, (width+1)*(height+1))
+	for y := 0; y <= height; y++ {
+		for x := 0; x <= width; x++ {
+			corners[y*(width+1)+x] = along(func(i int) float64 { return rows[i*(width+1)+x] }, height, y)
+		}
+	}
+	return corners
+}
+
+// Regrid maps source values, laid out on the Regridder's source grid, onto
+// its target grid. NaN is missing: a target point ignores missing source
+// points and renormalizes the weights of the rest, and is NaN when missing
+// points carry more than half its weight.
+func (r *Regridder) Regrid(values []float64) ([]float64, error) {
+	if len(values) != r.source.SizeX*r.source.SizeY {
+		return nil, fmt.Errorf("%d values do not fill the %dx%d regrid source", len(values), r.source.SizeX, r.source.SizeY)
+	}
+	result := make([]float64, len(r.offsets)-1)
+	for t := range result {
+		var sum, valid float64
+		for k := r.offsets[t]; k < r.offsets[t+1]; k++ {
+			v := values[r.sources[k]]
+			if math.IsNaN(v) {
+				continue
+			}
+			sum += r.weights[k] * v
+			valid += r.weights[k]
+		}
+		if valid < 0.5 {
+			result[t] = math.NaN()
+		} else {
+			result[t] = sum / valid
+		}
+	}
+	return result, nil
+}
+
+// RegridTransformer moves the grid onto r's target grid (see
+// Regridder.Regrid). The grid must have the size and coordinates of r's
+// source; errors for grids that don't wrap ErrGridMismatch.
+func RegridTransformer(r *Regridder) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := values.Validate(); err != nil {
+			return fmt.Errorf("error regridding: %w", err)
+		}
+		source := &GridValues{SizeX: r.source.SizeX, SizeY: r.source.SizeY, Lats: r.source.Lats, Lons: r.source.Lons, Geometry: r.source.Geometry}
+		if err := checkAligned(values, source); err != nil {
+			return fmt.Errorf("error regridding: %w", err)
+		}
+		regridded, err := r.Regrid(values.Values)
+		if err != nil {
+			return err
+		}
+		values.Values = regridded
+		values.SizeX, values.SizeY = r.target.SizeX, r.target.SizeY
+		values.Lats, values.Lons = slices.Clone(r.target.Lats), slices.Clone(r.target.Lons)
+		values.Geometry = r.target.Geometry
+		return nil
+	}
+}
+
+// locator finds lat/lon points in the index space of a source grid, where
+// column 0.5 is halfway between the first two columns.
+type locator struct {
+	sizeX, sizeY int
+	// periodic says the columns wrap all the way around the globe, so
+	// column sizeX is column 0 again.
+	periodic bool
+	position func(lat, lon float64) (x, y float64)
+}
+
+func newLocator(source GridDefinition) (locator, error) {
+	l := locator{sizeX: source.SizeX, sizeY: source.SizeY}
+	geometry := source.Geometry
+	if geometry == nil {
+		grid := source.grid()
+		if !grid.CompactCoordinates() {
+			return locator{}, fmt.Errorf("can't regrid from a curvilinear grid")
+		}
+		geometry = grid.Geometry
+	}
+	switch g := geometry.(type) {
+	case RegularLatLon:
+		period := 360 / math.Abs(g.DLon)
+		l.periodic = math.Abs(period-float64(source.SizeX)) < 1e-6
+		l.position = func(lat, lon float64) (float64, float64) {
+			return l.column(steps(lon-g.Lon0, g.DLon), period), steps(lat-g.Lat0, g.DLat)
+		}
+	case LatLonAxes:
+		lons := unwrapLons(g.Lons, len(g.Lons), 1)
+		first, last := lons[0], lons[len(lons)-1]
+		step := 0.0
+		if len(lons) > 1 {
+			step = math.Abs(last-first) / float64(len(lons)-1)
+		}
+		l.periodic = step > 0 && math.Abs(math.Abs(last-first)+step-360) < 1e-6
+		middle := (first + last) / 2
+		l.position = func(lat, lon float64) (float64, float64) {
+			x := axisPosition(lons, middle+normalizeLon(lon-middle))
+			if l.periodic {
+				x = l.column(x, float64(source.SizeX))
+			}
+			return x, axisPosition(g.Lats, lat)
+		}
+	case ProjectedGrid:
+		projection := prepare(g.Projection)
+		l.position = func(lat, lon float64) (float64, float64) {
+			px, py := projection.Forward(lat, lon)
+			return (px - g.X0) / g.DX, (py - g.Y0) / g.DY
+		}
+	default:
+		return locator{}, fmt.Errorf("can't regrid from a %T geometry", geometry)
+	}
+	return l, nil
+}
+
+// steps is how many steps of size step make up distance, which is zero
+// along an axis of one point, where the step is zero.
+func steps(distance, step float64) float64 {
+	if step == 0 {
+		return 0
+	}
+	return distance / step
+}
+
+// column brings column x, on an axis that repeats every period columns, as
+// close to the grid's columns as it gets.
+func (l locator) column(x, period float64) float64 {
+	x = math.Mod(x, period)
+	if x < 0 {
+		x += period
+	}
+	if !l.periodic && x > (float64(l.sizeX-1)+period)/2 {
+		x -= period
+	}
+	return x
+}
+
+// cell returns the index of the source cell containing index-space point
+// (x, y), and false when it is beyond the edges of the grid.
+func (l locator) cell(x, y float64) (int, bool) {
+	column, row := int(math.Floor(x+0.5)), int(math.Floor(y+0.5))
+	if math.IsNaN(x) || math.IsNaN(y) || row < 0 || row >= l.sizeY {
+		return 0, false
+	}
+	if l.periodic {
+		column %= l.sizeX
+		if column < 0 {
+			column += l.sizeX
+		}
+	} else if column < 0 || column >= l.sizeX {
+		return 0, false
+	}
+	return row*l.sizeX + column, true
+}
+
+// axisPosition returns the fractional index of v along a strictly monotonic
+// axis, extrapolating from the end steps beyond its ends. An axis of one
+// point counts as a step of one degree.
+func axisPosition(axis []float64, v float64) float64 {
+	n := len(axis)
+	if n == 1 {
+		return v - axis[0]
+	}
+	ascending := axis[n-1] > axis[0]
+	i := sort.Search(n, func(i int) bool {
+		if ascending {
+			return axis[i] >= v
+		}
+		return axis[i] <= v
+	})
+	i = min(max(i, 1), n-1)
+	return float64(i-1) + (v-axis[i-1])/(axis[i]-axis[i-1])
+}
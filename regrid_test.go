@@ -0,0 +1,257 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+)
+
+// planeGrid is a regular grid whose values are lat + 2*lon.
+func planeGrid(lat0, lon0, step float64, sizeX, sizeY int) *grid_to_isobands.GridValues {
+	values := &grid_to_isobands.GridValues{
+		SizeX:    sizeX,
+		SizeY:    sizeY,
+		Values:   make([]float64, sizeX*sizeY),
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: lat0, Lon0: lon0, DLat: step, DLon: step},
+	}
+	for y := range sizeY {
+		for x := range sizeX {
+			lat, lon := values.LatLon(x, y)
+			values.Values[y*sizeX+x] = lat + 2*lon
+		}
+	}
+	return values
+}
+
+func TestRegridBilinearKeepsPlanes(t *testing.T) {
+	source := planeGrid(30, -100, 1, 11, 11)
+	target := grid_to_isobands.GridDefinition{
+		SizeX:    9,
+		SizeY:    5,
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 31.3, Lon0: -99.7, DLat: 0.7, DLon: 0.9},
+	}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridBilinear)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if err := grid_to_isobands.RegridTransformer(r)(context.Background(), source); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if source.SizeX != 9 || source.SizeY != 5 || source.Geometry != target.Geometry {
+		t.Fatalf(`expected the target grid, got %+v`, source)
+	}
+	for y := range 5 {
+		for x := range 9 {
+			lat, lon := source.LatLon(x, y)
+			if actual := source.Values[y*9+x]; math.Abs(actual-(lat+2*lon)) > 1e-9 {
+				t.Fatalf(`expected %v at %v,%v, got %v`, lat+2*lon, lat, lon, actual)
+			}
+		}
+	}
+}
+
+func TestRegridAreaAverageIgnoresMissingCells(t *testing.T) {
+	// Each 1° target cell covers exactly 4x4 source cells of 0.25°.
+	source := planeGrid(0, 0, 0.25, 8, 8)
+	source.Values[0] = math.NaN()
+	target := grid_to_isobands.GridDefinition{
+		SizeX:    3,
+		SizeY:    2,
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 0.375, Lon0: 0.375, DLat: 1, DLon: 1},
+	}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridAreaAverage)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	for _, frame := range []float64{0, 10} {
+		values := make([]float64, len(source.Values))
+		for i, v := range source.Values {
+			values[i] = v + frame
+		}
+		regridded, err := r.Regrid(values)
+		if err != nil {
+			t.Fatalf(`expected no error, got %v`, err)
+		}
+		// The first cell averages the 15 source cells that aren't missing.
+		first := 0.0
+		for i := 1; i < 16; i++ {
+			first += values[(i/4)*8+i%4]
+		}
+		expected := []float64{first / 15, 0.375 + 2*1.375 + frame, math.NaN(), 1.375 + 2*0.375 + frame, 1.375 + 2*1.375 + frame, math.NaN()}
+		for i := range expected {
+			if math.IsNaN(expected[i]) != math.IsNaN(regridded[i]) || math.Abs(regridded[i]-expected[i]) > 1e-9 {
+				t.Fatalf(`expected %v, got %v`, expected, regridded)
+			}
+		}
+	}
+}
+
+func TestRegridAreaAverageCoversLargeCells(t *testing.T) {
+	// Each 10° target cell covers 40x40 source cells of 0.25°, each of which
+	// must count equally.
+	source := planeGrid(0, 0, 0.25, 80, 80)
+	for i := range source.Values {
+		source.Values[i] = float64((i * 7919) % 101)
+	}
+	target := grid_to_isobands.GridDefinition{
+		SizeX:    2,
+		SizeY:    2,
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 4.875, Lon0: 4.875, DLat: 10, DLon: 10},
+	}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridAreaAverage)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	regridded, err := r.Regrid(source.Values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	for cell := range 4 {
+		x0, y0 := cell%2*40, cell/2*40
+		total := 0.0
+		for y := y0; y < y0+40; y++ {
+			for x := x0; x < x0+40; x++ {
+				total += source.Values[y*80+x]
+			}
+		}
+		if expected := total / 1600; math.Abs(regridded[cell]-expected) > 1e-9 {
+			t.Fatalf(`expected %v in cell %d, got %v`, expected, cell, regridded[cell])
+		}
+	}
+}
+
+func TestRegridConservativeKeepsTotals(t *testing.T) {
+	// 1.5° target cells split the 1° source cells they straddle, well away
+	// from the equator where cell areas shrink noticeably from row to row.
+	source := planeGrid(60.5, 0.5, 1, 3, 3)
+	for i := range source.Values {
+		source.Values[i] = float64((i * 37) % 11)
+	}
+	target := grid_to_isobands.GridDefinition{
+		SizeX:    2,
+		SizeY:    2,
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 60.75, Lon0: 0.75, DLat: 1.5, DLon: 1.5},
+	}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridConservative)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	regridded, err := r.Regrid(source.Values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	// The area of a cell spanning lat-step/2..lat+step/2 and step degrees
+	// of longitude, in units of the sphere's radius squared times degrees.
+	area := func(lat, step float64) float64 {
+		sin := func(lat float64) float64 { return math.Sin(lat * math.Pi / 180) }
+		return (sin(lat+step/2) - sin(lat-step/2)) * step
+	}
+	before, after := 0.0, 0.0
+	for i, v := range source.Values {
+		before += v * area(60.5+float64(i/3), 1)
+	}
+	for i, v := range regridded {
+		after += v * area(60.75+1.5*float64(i/2), 1.5)
+	}
+	if math.Abs(after-before) > 1e-12 {
+		t.Fatalf(`expected a total of %v, got %v`, before, after)
+	}
+}
+
+func TestRegridConservativeNeedsLatLonAxes(t *testing.T) {
+	source := planeGrid(30, -100, 1, 11, 11)
+	target := grid_to_isobands.GridDefinition{
+		SizeX:    2,
+		SizeY:    2,
+		Geometry: grid_to_isobands.NewProjectedGrid(grid_to_isobands.Mercator{Radius: 6371000}, 32, -98, 100000, 100000),
+	}
+	_, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridConservative)
+	if err == nil {
+		t.Fatalf(`expected an error regridding conservatively onto a projected grid, got nil`)
+	}
+}
+
+func TestRegridTransformerRejectsOtherGrids(t *testing.T) {
+	source := planeGrid(30, -100, 1, 11, 11)
+	target := grid_to_isobands.GridDefinition{
+		SizeX:    3,
+		SizeY:    3,
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 32, Lon0: -98, DLat: 2, DLon: 2},
+	}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridNearest)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	other := planeGrid(40, -100, 1, 11, 11)
+	err = grid_to_isobands.RegridTransformer(r)(context.Background(), other)
+	if !errors.Is(err, grid_to_isobands.ErrGridMismatch) {
+		t.Fatalf(`expected %v, got %v`, grid_to_isobands.ErrGridMismatch, err)
+	}
+}
+
+func TestRegridWrapsGlobalGrids(t *testing.T) {
+	source := &grid_to_isobands.GridValues{
+		SizeX:    36,
+		SizeY:    3,
+		Values:   make([]float64, 36*3),
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: -10, Lon0: 0, DLat: 10, DLon: 10},
+	}
+	for i := range source.Values {
+		source.Values[i] = float64(i % 36)
+	}
+	target := grid_to_isobands.GridDefinition{SizeX: 2, SizeY: 1, Lats: []float64{0, 0}, Lons: []float64{-5, 175}}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridBilinear)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	actual, err := r.Regrid(source.Values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if actual[0] != 17.5 || actual[1] != 17.5 {
+		t.Fatalf(`expected [17.5 17.5], got %v`, actual)
+	}
+}
+
+func TestRegridFromProjectedGrid(t *testing.T) {
+	projection := grid_to_isobands.LambertConformal{Lat1: 38.5, Lat2: 38.5, Lat0: 38.5, Lon0: 262.5}
+	source := &grid_to_isobands.GridValues{
+		SizeX:    20,
+		SizeY:    15,
+		Values:   make([]float64, 20*15),
+		Geometry: grid_to_isobands.NewProjectedGrid(projection, 35, -100, 3000, 3000),
+	}
+	for i := range source.Values {
+		source.Values[i] = float64(i)
+	}
+	// The target is a handful of the source points themselves.
+	target := grid_to_isobands.GridDefinition{SizeX: 3, SizeY: 1, Lats: make([]float64, 3), Lons: make([]float64, 3)}
+	points := []int{0, 7*20 + 11, 14*20 + 19}
+	for i, p := range points {
+		target.Lats[i], target.Lons[i] = source.LatLon(p%20, p/20)
+	}
+	r, err := grid_to_isobands.NewRegridder(context.Background(), source.Definition(), target, grid_to_isobands.RegridNearest)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	actual, err := r.Regrid(source.Values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	for i, p := range points {
+		if actual[i] != float64(p) {
+			t.Fatalf(`expected %v, got %v`, points, actual)
+		}
+	}
+}
+
+func TestRegridRejectsCurvilinearSources(t *testing.T) {
+	source := grid_to_isobands.GridDefinition{SizeX: 2, SizeY: 2, Lats: []float64{0, 0.1, 1, 1.1}, Lons: []float64{0, 1, 0, 1}}
+	target := grid_to_isobands.GridDefinition{SizeX: 1, SizeY: 1, Geometry: grid_to_isobands.RegularLatLon{}}
+	if _, err := grid_to_isobands.NewRegridder(context.Background(), source, target, grid_to_isobands.RegridNearest); err == nil {
+		t.Fatalf(`expected an error, got nil`)
+	}
+}
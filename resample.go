@@ -0,0 +1,108 @@
+package grid_to_isobands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// ResampleTransformer scales the grid by factor along both axes, interpolating
+// the values with m (see transformers.Resample), so coarse fields contour into
+// smooth bands rather than blocky ones. The new points are spaced 1/factor
+// apart in grid index from the first row and column, so with a fractional
+// factor they can stop short of the last ones (see
+// transformers.ResamplePositions): regular and projected geometries get a
+// finer step, and axes and per-point coordinates are interpolated linearly.
+func ResampleTransformer(factor float64, m transformers.Interpolation, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := values.Validate(); err != nil {
+			return err
+		}
+		resampled, sizeX, sizeY, err := transformers.Resample(ctx, values.Values, values.SizeX, values.SizeY, factor, m, opts...)
+		if err != nil {
+			return fmt.Errorf("error resampling grid: %w", err)
+		}
+		xs := transformers.ResamplePositions(values.SizeX, factor)
+		ys := transformers.ResamplePositions(values.SizeY, factor)
+		switch g := values.Geometry.(type) {
+		case RegularLatLon:
+			g.DLat /= factor
+			g.DLon /= factor
+			values.Geometry = g
+		case ProjectedGrid:
+			g.DX /= factor
+			g.DY /= factor
+			values.Geometry = g
+		case LatLonAxes:
+			lats, err := interpolateCoordinates(ctx, g.Lats, 1, len(g.Lats), []float64{0}, ys)
+			if err != nil {
+				return err
+			}
+			lons, err := interpolateCoordinates(ctx, unwrapLons(g.Lons, len(g.Lons), 1), len(g.Lons), 1, xs, []float64{0})
+			if err != nil {
+				return err
+			}
+			values.Geometry = LatLonAxes{Lats: lats, Lons: wrapLons(lons)}
+		default:
+			values.ExpandCoordinates()
+			lats, err := interpolateCoordinates(ctx, values.Lats, values.SizeX, values.SizeY, xs, ys)
+			if err != nil {
+				return err
+			}
+			lons, err := interpolateCoordinates(ctx, unwrapLons(values.Lons, values.SizeX, values.SizeY), values.SizeX, values.SizeY, xs, ys)
+			if err != nil {
+				return err
+			}
+			values.Lats, values.Lons = lats, wrapLons(lons)
+		}
+		values.Values = resampled
+		values.SizeX, values.SizeY = sizeX, sizeY
+		return nil
+	}
+}
+
+// interpolateCoordinates samples a coordinate grid bilinearly at the given
+// fractional columns and rows.
+func interpolateCoordinates(ctx context.Context, coordinates []float64, sizeX, sizeY int, xs, ys []float64) ([]float64, error) {
+	interpolated, err := transformers.Interpolate(ctx, coordinates, sizeX, sizeY, xs, ys, transformers.InterpolateBilinear)
+	if err != nil {
+		return nil, fmt.Errorf("error interpolating coordinates: %w", err)
+	}
+	return interpolated, nil
+}
+
+// unwrapLons returns a copy of a grid of longitudes with multiples of 360
+// added so that no two neighbors along a row, or down the first column,
+// differ by more than 180, so interpolating between them doesn't sweep the
+// long way round the globe.
+func unwrapLons(lons []float64, sizeX, sizeY int) []float64 {
+	unwrapped := make([]float64, len(lons))
+	for y := 0; y < sizeY; y++ {
+		row := y * sizeX
+		previous := lons[row]
+		if y > 0 {
+			previous = unwrapped[row-sizeX]
+		}
+		for x := 0; x < sizeX; x++ {
+			lon := previous + normalizeLon(lons[row+x]-previous)
+			unwrapped[row+x] = lon
+			previous = lon
+		}
+	}
+	return unwrapped
+}
+
+// wrapLons brings unwrapped longitudes back within minLon..maxLon in place.
+func wrapLons(lons []float64) []float64 {
+	for i, lon := range lons {
+		for lon > maxLon {
+			lon -= 360
+		}
+		for lon < minLon {
+			lon += 360
+		}
+		lons[i] = lon
+	}
+	return lons
+}
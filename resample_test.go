@@ -0,0 +1,60 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+func TestResampleRegularGrid(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:    3,
+		SizeY:    2,
+		Values:   []float64{0, 2, 4, 10, 12, 14},
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 40, Lon0: -100, DLat: -0.25, DLon: 0.25},
+	}
+	transform := grid_to_isobands.ResampleTransformer(2, transformers.InterpolateBilinear)
+	if err := transform(context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	expected := &grid_to_isobands.GridValues{
+		SizeX: 5,
+		SizeY: 3,
+		Values: []float64{
+			0, 1, 2, 3, 4,
+			5, 6, 7, 8, 9,
+			10, 11, 12, 13, 14,
+		},
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 40, Lon0: -100, DLat: -0.125, DLon: 0.125},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, values)
+	}
+}
+
+func TestResampleCoordinatesAcrossAntimeridian(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:  2,
+		SizeY:  2,
+		Values: []float64{1, 3, 5, 7},
+		Lats:   []float64{10, 11, 20, 21},
+		Lons:   []float64{179, -179, 178, -178},
+	}
+	transform := grid_to_isobands.ResampleTransformer(2, transformers.InterpolateBilinear)
+	if err := transform(context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if err := values.Validate(); err != nil {
+		t.Fatalf(`expected a valid grid, got %v`, err)
+	}
+	expectedLons := []float64{179, 180, 181, 178.5, 180, 181.5, 178, 180, 182}
+	if !reflect.DeepEqual(values.Lons, expectedLons) {
+		t.Fatalf(`expected %v, got %v`, expectedLons, values.Lons)
+	}
+	if actual := values.Lats[4]; actual != 15.5 {
+		t.Fatalf(`expected 15.5, got %v`, actual)
+	}
+}
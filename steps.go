@@ -0,0 +1,162 @@
+package grid_to_isobands
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// The transformers of this package are registered for pipelines under the
+// names below. Filters also take the transformers.Options parameters
+// "workers", "preserveNaN" and "boundary" (one of "clamp", "reflect",
+// "wrap-x" and "nan"). Predicates are given as "greaterThan" or "lessThan",
+// and replacements default to NaN.
+func init() {
+	RegisterTransformer(`swap-right-left`, func(p *Params) (GridTransformer, error) {
+		return SwapRightAndLeftTransformer(), nil
+	})
+	RegisterTransformer(`reverse-vertical`, func(p *Params) (GridTransformer, error) {
+		return ReverseVerticalTransformer(), nil
+	})
+	RegisterTransformer(`remove-inf`, func(p *Params) (GridTransformer, error) {
+		return RemoveInfTransformer(), nil
+	})
+	RegisterTransformer(`clip`, func(p *Params) (GridTransformer, error) {
+		return ClipTransformer(transformers.Clip{
+			Top:    p.IntOr(`top`, 0),
+			Bottom: p.IntOr(`bottom`, 0),
+			Left:   p.IntOr(`left`, 0),
+			Right:  p.IntOr(`right`, 0),
+		}), nil
+	})
+	RegisterTransformer(`crop`, func(p *Params) (GridTransformer, error) {
+		return CropTransformer(orb.Bound{
+			Min: orb.Point{p.Float(`minLon`), p.Float(`minLat`)},
+			Max: orb.Point{p.Float(`maxLon`), p.Float(`maxLat`)},
+		}), nil
+	})
+	RegisterTransformer(`threshold-mask`, func(p *Params) (GridTransformer, error) {
+		return ThresholdMaskTransformer(threshold(p), p.FloatOr(`replacement`, math.NaN())), nil
+	})
+	RegisterTransformer(`gaussian`, func(p *Params) (GridTransformer, error) {
+		return GaussianTransformer(p.Int(`kernel`), p.Float(`sigma`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`median`, func(p *Params) (GridTransformer, error) {
+		return MedianTransformer(p.Int(`kernel`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`open-close`, func(p *Params) (GridTransformer, error) {
+		return OpenCloseTransformer(p.Int(`kernel`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`close-open`, func(p *Params) (GridTransformer, error) {
+		return CloseOpenTransformer(p.Int(`kernel`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`bilateral`, func(p *Params) (GridTransformer, error) {
+		return BilateralTransformer(p.Float(`sigma`), p.Float(`color`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`speckle`, func(p *Params) (GridTransformer, error) {
+		return SpeckleTransformer(speckle(p), filterOptions(p)), nil
+	})
+	RegisterTransformer(`hysteresis`, func(p *Params) (GridTransformer, error) {
+		return HysteresisTransformer(transformers.Hysteresis{
+			Weak:         transformers.GreaterThan(p.Float(`weak`)),
+			Strong:       transformers.GreaterThan(p.Float(`strong`)),
+			Connectivity: transformers.Connectivity(p.IntOr(`connectivity`, 0)),
+			Replacement:  p.FloatOr(`replacement`, math.NaN()),
+		}, filterOptions(p)), nil
+	})
+	RegisterTransformer(`gap-fill`, func(p *Params) (GridTransformer, error) {
+		return GapFillTransformer(gapFill(p), filterOptions(p)), nil
+	})
+	RegisterTransformer(`resample`, func(p *Params) (GridTransformer, error) {
+		return ResampleTransformer(p.Float(`factor`), interpolation(p), filterOptions(p)), nil
+	})
+	RegisterTransformer(`unit`, func(p *Params) (GridTransformer, error) {
+		return UnitTransformer(Unit(p.StringOr(`from`, "")), Unit(p.String(`to`))), nil
+	})
+	RegisterTransformer(`geodesic-gaussian`, func(p *Params) (GridTransformer, error) {
+		return GeodesicGaussianTransformer(p.Float(`sigmaKm`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`geodesic-median`, func(p *Params) (GridTransformer, error) {
+		return GeodesicMedianTransformer(p.Float(`radiusKm`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`geodesic-open-close`, func(p *Params) (GridTransformer, error) {
+		return GeodesicOpenCloseTransformer(p.Float(`radiusKm`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`geodesic-close-open`, func(p *Params) (GridTransformer, error) {
+		return GeodesicCloseOpenTransformer(p.Float(`radiusKm`), filterOptions(p)), nil
+	})
+	RegisterTransformer(`geodesic-speckle`, func(p *Params) (GridTransformer, error) {
+		return GeodesicSpeckleTransformer(speckle(p), filterOptions(p)), nil
+	})
+	RegisterTransformer(`geodesic-gap-fill`, func(p *Params) (GridTransformer, error) {
+		return GeodesicGapFillTransformer(gapFill(p), filterOptions(p)), nil
+	})
+}
+
+// filterOptions reads the transformers.Options parameters.
+func filterOptions(p *Params) transformers.Options {
+	return transformers.Options{
+		Workers:     p.IntOr(`workers`, 0),
+		PreserveNaN: p.BoolOr(`preserveNaN`, false),
+		Boundary:    named(p, `boundary`, transformers.BoundaryClamp, transformers.BoundaryNaN, transformers.BoundaryDefault),
+	}
+}
+
+// threshold reads a predicate given as exactly one of "greaterThan" and
+// "lessThan".
+func threshold(p *Params) transformers.ThresholdFunc {
+	switch {
+	case p.Has(`greaterThan`) && !p.Has(`lessThan`):
+		return transformers.GreaterThan(p.Float(`greaterThan`))
+	case p.Has(`lessThan`) && !p.Has(`greaterThan`):
+		return transformers.LessThan(p.Float(`lessThan`))
+	default:
+		p.fail("exactly one of %q and %q is required", `greaterThan`, `lessThan`)
+		return nil
+	}
+}
+
+func speckle(p *Params) transformers.Speckle {
+	return transformers.Speckle{
+		Member:       threshold(p),
+		MinArea:      p.Float(`minArea`),
+		Connectivity: transformers.Connectivity(p.IntOr(`connectivity`, 0)),
+		Replacement:  p.FloatOr(`replacement`, math.NaN()),
+	}
+}
+
+func gapFill(p *Params) transformers.GapFill {
+	return transformers.GapFill{
+		Method:       named(p, `method`, transformers.FillInverseDistance, transformers.FillDiffusion, transformers.FillInverseDistance),
+		MaxArea:      p.Float(`maxArea`),
+		Connectivity: transformers.Connectivity(p.IntOr(`connectivity`, 0)),
+		Power:        p.FloatOr(`power`, 0),
+		Iterations:   p.IntOr(`iterations`, 0),
+		Tolerance:    p.FloatOr(`tolerance`, 0),
+	}
+}
+
+func interpolation(p *Params) transformers.Interpolation {
+	return named(p, `interpolation`, transformers.InterpolateNearest, transformers.InterpolateLanczos, transformers.InterpolateBilinear)
+}
+
+// named reads an optional parameter naming one of the values from first to
+// last by its String, or returns fallback when it isn't set.
+func named[T interface {
+	~int
+	fmt.Stringer
+}](p *Params, name string, first, last, fallback T) T {
+	if !p.Has(name) {
+		return fallback
+	}
+	s := p.String(name)
+	for v := first; v <= last; v++ {
+		if v.String() == s {
+			return v
+		}
+	}
+	p.fail("unknown %v %q", name, s)
+	return fallback
+}
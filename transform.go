@@ -1,116 +1,424 @@
 package grid_to_isobands
 
 import (
+	"context"
+	"fmt"
 	"math"
+	"slices"
 
+	"github.com/paulmach/orb"
 	"github.com/skysparq/grid-to-isobands/transformers"
 )
 
-type GridTransformer func(values *GridValues)
+// GridTransformer modifies a grid in place before it is contoured. A
+// returned error stops preprocessing (see PreprocessError). Long-running
+// transformers check ctx as they go and return ctx.Err() once it is
+// cancelled.
+type GridTransformer func(ctx context.Context, values *GridValues) error
 
-func SwapRightAndLeftTransformer() GridTransformer {
-	return func(values *GridValues) {
-		width := values.SizeX
-		height := len(values.Values) / width
-		halfWidth := width / 2
-		for i := 0; i < height; i++ {
-			leftStart := i * width
-			leftEnd := leftStart + halfWidth
-			rightStart := leftStart + width - halfWidth
-			rightEnd := rightStart + halfWidth
-			left := make([]float64, width)
-			copy(left, values.Values[leftStart:leftEnd])
-			copy(values.Values[leftStart:leftEnd], values.Values[rightStart:rightEnd])
-			copy(values.Values[rightStart:rightEnd], left)
+// TransformerFromFunc adapts a function that can't fail into a
+// GridTransformer. The function runs to completion regardless of ctx.
+func TransformerFromFunc(transform func(values *GridValues)) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		transform(values)
+		return nil
+	}
+}
 
-			copy(left, values.Lats[leftStart:leftEnd])
-			copy(values.Lats[leftStart:leftEnd], values.Lats[rightStart:rightEnd])
-			copy(values.Lats[rightStart:rightEnd], left)
+// checkSize reports grids whose values don't fill SizeX by SizeY, which the
+// filters in the transformers package can't process.
+func checkSize(values *GridValues) error {
+	if values.SizeX <= 0 || values.SizeY <= 0 || len(values.Values) != values.SizeX*values.SizeY {
+		return fmt.Errorf("%d values do not fill a %dx%d grid", len(values.Values), values.SizeX, values.SizeY)
+	}
+	return nil
+}
 
-			copy(left, values.Lons[leftStart:leftEnd])
-			copy(values.Lons[leftStart:leftEnd], values.Lons[rightStart:rightEnd])
-			copy(values.Lons[rightStart:rightEnd], left)
+func SwapRightAndLeftTransformer() GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := checkSize(values); err != nil {
+			return err
+		}
+		swapRowHalves(values.Values, values.SizeX)
+		if values.Geometry == nil {
+			swapRowHalves(values.Lats, values.SizeX)
+			swapRowHalves(values.Lons, values.SizeX)
+			return nil
+		}
+		axes, ok := toAxes(values.Geometry, values.SizeX, values.SizeY)
+		if !ok {
+			values.ExpandCoordinates()
+			swapRowHalves(values.Lats, values.SizeX)
+			swapRowHalves(values.Lons, values.SizeX)
+			return nil
 		}
+		swapRowHalves(axes.Lons, values.SizeX)
+		values.Geometry = axes
+		return nil
+	}
+}
+
+// swapRowHalves swaps the left and right halves of every row in place. For
+// odd widths the middle column stays where it is.
+func swapRowHalves(data []float64, width int) {
+	height := len(data) / width
+	halfWidth := width / 2
+	left := make([]float64, width)
+	for i := 0; i < height; i++ {
+		leftStart := i * width
+		leftEnd := leftStart + halfWidth
+		rightStart := leftStart + width - halfWidth
+		rightEnd := rightStart + halfWidth
+		copy(left, data[leftStart:leftEnd])
+		copy(data[leftStart:leftEnd], data[rightStart:rightEnd])
+		copy(data[rightStart:rightEnd], left)
 	}
 }
 
 func ReverseVerticalTransformer() GridTransformer {
-	return func(values *GridValues) {
-		width := values.SizeX
-		height := len(values.Values) / width
-		maxY := height - 1
-		bottomCopy := make([]float64, width)
-		for i := 0; i < height/2; i++ {
-			bottomStart := i * width
-			bottomEnd := bottomStart + width
-			topStart := (maxY - i) * width
-			topEnd := topStart + width
-			copy(bottomCopy, values.Values[bottomStart:bottomEnd])
-			copy(values.Values[bottomStart:bottomEnd], values.Values[topStart:topEnd])
-			copy(values.Values[topStart:topEnd], bottomCopy)
+	return func(ctx context.Context, values *GridValues) error {
+		if err := checkSize(values); err != nil {
+			return err
+		}
+		reverseRows(values.Values, values.SizeX)
+		switch g := values.Geometry.(type) {
+		case nil:
+			reverseRows(values.Lats, values.SizeX)
+			reverseRows(values.Lons, values.SizeX)
+		case RegularLatLon:
+			g.Lat0 += float64(values.SizeY-1) * g.DLat
+			g.DLat = -g.DLat
+			values.Geometry = g
+		case LatLonAxes:
+			axes, _ := toAxes(g, values.SizeX, values.SizeY)
+			reverseRows(axes.Lats, 1)
+			values.Geometry = axes
+		case ProjectedGrid:
+			g.X0, g.Y0 = g.XY(0, values.SizeY-1)
+			g.DY = -g.DY
+			values.Geometry = g
+		default:
+			values.ExpandCoordinates()
+			reverseRows(values.Lats, values.SizeX)
+			reverseRows(values.Lons, values.SizeX)
+		}
+		return nil
+	}
+}
 
-			copy(bottomCopy, values.Lats[bottomStart:bottomEnd])
-			copy(values.Lats[bottomStart:bottomEnd], values.Lats[topStart:topEnd])
-			copy(values.Lats[topStart:topEnd], bottomCopy)
+// reverseRows reverses the order of the rows of a row-major grid in place.
+func reverseRows(data []float64, width int) {
+	height := len(data) / width
+	maxY := height - 1
+	bottomCopy := make([]float64, width)
+	for i := 0; i < height/2; i++ {
+		bottomStart := i * width
+		bottomEnd := bottomStart + width
+		topStart := (maxY - i) * width
+		topEnd := topStart + width
+		copy(bottomCopy, data[bottomStart:bottomEnd])
+		copy(data[bottomStart:bottomEnd], data[topStart:topEnd])
+		copy(data[topStart:topEnd], bottomCopy)
+	}
+}
 
-			copy(bottomCopy, values.Lons[bottomStart:bottomEnd])
-			copy(values.Lons[bottomStart:bottomEnd], values.Lons[topStart:topEnd])
-			copy(values.Lons[topStart:topEnd], bottomCopy)
+func OpenCloseTransformer(kernel int, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := checkMorphology(values, kernel); err != nil {
+			return err
 		}
+		morphOps := transformers.NewMorphologicalOps(values.SizeX, values.SizeY, opts...)
+		opened, err := morphOps.OpenClose(ctx, values.Values, kernel)
+		if err != nil {
+			return fmt.Errorf("error applying open-close: %w", err)
+		}
+		values.Values = opened
+		return nil
 	}
 }
 
-func OpenCloseTransformer(kernel int) GridTransformer {
-	return func(values *GridValues) {
-		morphOps := transformers.NewMorphologicalOps(values.SizeX, values.SizeY)
-		values.Values = morphOps.OpenClose(values.Values, kernel)
+func CloseOpenTransformer(kernel int, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := checkMorphology(values, kernel); err != nil {
+			return err
+		}
+		morphOps := transformers.NewMorphologicalOps(values.SizeX, values.SizeY, opts...)
+		closed, err := morphOps.CloseOpen(ctx, values.Values, kernel)
+		if err != nil {
+			return fmt.Errorf("error applying close-open: %w", err)
+		}
+		values.Values = closed
+		return nil
 	}
 }
 
-func CloseOpenTransformer(kernel int) GridTransformer {
-	return func(values *GridValues) {
-		morphOps := transformers.NewMorphologicalOps(values.SizeX, values.SizeY)
-		values.Values = morphOps.CloseOpen(values.Values, kernel)
+func checkMorphology(values *GridValues, kernel int) error {
+	if kernel < 1 {
+		return fmt.Errorf("kernel size must be at least 1, got %d", kernel)
 	}
+	return checkSize(values)
 }
 
-func GaussianTransformer(kernel int, sigma float64) GridTransformer {
-	return func(values *GridValues) {
-		values.Values = transformers.FastGaussian(values.Values, values.SizeX, values.SizeY, kernel, sigma)
+func GaussianTransformer(kernel int, sigma float64, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		smoothed, err := transformers.FastGaussian(ctx, values.Values, values.SizeX, values.SizeY, kernel, sigma, opts...)
+		if err != nil {
+			return fmt.Errorf("error applying gaussian filter: %w", err)
+		}
+		values.Values = smoothed
+		return nil
 	}
 }
 
-func MedianTransformer(kernel int) GridTransformer {
-	return func(values *GridValues) {
-		newValues, _ := transformers.MedianFilter(values.Values, values.SizeX, values.SizeY, kernel)
+func MedianTransformer(kernel int, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		newValues, err := transformers.MedianFilter(ctx, values.Values, values.SizeX, values.SizeY, kernel, opts...)
+		if err != nil {
+			return fmt.Errorf("error applying median filter: %w", err)
+		}
 		values.Values = newValues
+		return nil
 	}
 }
 
 func ClipTransformer(clip transformers.Clip) GridTransformer {
-	return func(values *GridValues) {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := checkSize(values); err != nil {
+			return err
+		}
 		transformers.ClipGrid(values.Values, values.SizeX, values.SizeY, clip)
+		return nil
+	}
+}
+
+// CropTransformer shrinks the grid to the smallest index window containing
+// every point inside bound, so later transformers and contouring only run on
+// that region. Longitudes are compared modulo 360, so a -180..180 bound works
+// against a 0..360 grid, and a bound whose Min.Lon is greater than its Max.Lon
+// crosses the antimeridian. When the window spans the seam of a grid that
+// goes all the way around the globe, such as -10..10 on a 0..360 grid, its
+// columns are rolled together and their longitudes made continuous; on a grid
+// that doesn't wrap, such a window is an error. If no point falls inside
+// bound, every value is set to NaN and no isobands are produced.
+func CropTransformer(bound orb.Bound) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		if err := checkSize(values); err != nil {
+			return err
+		}
+		window, ok, err := cropWindow(ctx, values, bound)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			for i := range values.Values {
+				values.Values[i] = math.NaN()
+			}
+			return nil
+		}
+		wrapped := window.X+window.Width > values.SizeX
+		values.Values = transformers.CropGrid(values.Values, values.SizeX, window)
+		switch g := values.Geometry.(type) {
+		case RegularLatLon:
+			g.Lat0, g.Lon0 = g.LatLon(window.X, window.Y)
+			if last := g.Lon0 + float64(window.Width-1)*g.DLon; last > maxLon || last < minLon {
+				g.Lon0 -= math.Copysign(360, last)
+			}
+			values.Geometry = g
+		case LatLonAxes:
+			lons := transformers.CropGrid(g.Lons, len(g.Lons), transformers.Window{X: window.X, Width: window.Width, Height: 1})
+			if wrapped {
+				lons = continuousLons(lons, window.Width, 1)
+			}
+			values.Geometry = LatLonAxes{
+				Lats: transformers.CropGrid(g.Lats, 1, transformers.Window{Y: window.Y, Width: 1, Height: window.Height}),
+				Lons: lons,
+			}
+		case ProjectedGrid:
+			g.X0, g.Y0 = g.XY(window.X, window.Y)
+			values.Geometry = g
+		default:
+			values.ExpandCoordinates()
+			values.Lats = transformers.CropGrid(values.Lats, values.SizeX, window)
+			values.Lons = transformers.CropGrid(values.Lons, values.SizeX, window)
+			if wrapped {
+				values.Lons = continuousLons(values.Lons, window.Width, window.Height)
+			}
+		}
+		values.SizeX = window.Width
+		values.SizeY = window.Height
+		return nil
+	}
+}
+
+// cropWindow finds the window of points inside bound, checking ctx once per
+// row since projected grids pay for an inverse projection at every point. On
+// a grid that wraps around the globe the columns are taken as a circle, and
+// the window runs from the end of the widest gap between columns with points
+// inside bound, so it may run past the right edge.
+func cropWindow(ctx context.Context, values *GridValues, bound orb.Bound) (transformers.Window, bool, error) {
+	minX, minY := values.SizeX, values.SizeY
+	maxX, maxY := -1, -1
+	inside := make([]bool, values.SizeX)
+	latLon := values.latLons()
+	for y := 0; y < values.SizeY; y++ {
+		if err := ctx.Err(); err != nil {
+			return transformers.Window{}, false, err
+		}
+		for x := 0; x < values.SizeX; x++ {
+			lat, lon := latLon(x, y)
+			if !boundContains(bound, lat, lon) {
+				continue
+			}
+			inside[x] = true
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+	if maxX < 0 {
+		return transformers.Window{}, false, nil
+	}
+	window := transformers.Window{X: minX, Y: minY, Width: maxX - minX + 1, Height: maxY - minY + 1}
+
+	// The widest run of columns without points inside bound between minX and
+	// maxX; if it is wider than the columns outside them, the window is
+	// shorter going the other way, across the edges of the grid.
+	gapStart, gapWidth := 0, 0
+	for x := minX; x <= maxX; {
+		if inside[x] {
+			x++
+			continue
+		}
+		start := x
+		for !inside[x] {
+			x++
+		}
+		if x-start > gapWidth {
+			gapStart, gapWidth = start, x-start
+		}
+	}
+	if gapWidth <= values.SizeX-window.Width {
+		return window, true, nil
+	}
+	if !wrapsAround(values) {
+		return transformers.Window{}, false, fmt.Errorf("error cropping to %v: the points inside it are at both edges of a grid that doesn't wrap around the globe", bound)
+	}
+	window.X = gapStart + gapWidth
+	window.Width = values.SizeX - gapWidth
+	return window, true, nil
+}
+
+// boundContains reports whether the point is inside bound, comparing
+// longitudes modulo 360. A bound whose Min.Lon is greater than its Max.Lon
+// runs east from Min.Lon across the antimeridian to Max.Lon.
+func boundContains(bound orb.Bound, lat, lon float64) bool {
+	if lat < bound.Min.Lat() || lat > bound.Max.Lat() {
+		return false
+	}
+	maxLon := bound.Max.Lon()
+	if maxLon < bound.Min.Lon() {
+		maxLon += 360
+	}
+	lon = bound.Min.Lon() + math.Mod(lon-bound.Min.Lon(), 360)
+	if lon < bound.Min.Lon() {
+		lon += 360
+	}
+	return lon <= maxLon
+}
+
+// wrapsAround reports whether the columns of a lat/lon grid go all the way
+// around the globe, so its last column is next to its first. Only the first
+// row is checked.
+func wrapsAround(values *GridValues) bool {
+	if _, ok := values.Geometry.(ProjectedGrid); ok || values.SizeX < 2 {
+		return false
+	}
+	lons := make([]float64, values.SizeX)
+	for x := range lons {
+		_, lons[x] = values.LatLon(x, 0)
+	}
+	lons = unwrapLons(lons, values.SizeX, 1)
+	span := math.Abs(lons[values.SizeX-1] - lons[0])
+	step := span / float64(values.SizeX-1)
+	return math.Abs(span+step-360) < 1e-6
+}
+
+// continuousLons unwraps a grid of longitudes whose rows were rolled across
+// the seam of a global grid, so they don't jump by 360 in the middle, and
+// brings them back within minLon..maxLon.
+func continuousLons(lons []float64, sizeX, sizeY int) []float64 {
+	lons = unwrapLons(lons, sizeX, sizeY)
+	if slices.Max(lons) > maxLon {
+		for i := range lons {
+			lons[i] -= 360
+		}
 	}
+	return lons
 }
 
 func ThresholdMaskTransformer(f transformers.ThresholdFunc, replacement float64) GridTransformer {
-	return func(values *GridValues) {
+	return func(ctx context.Context, values *GridValues) error {
 		transformers.ThresholdMask(values.Values, f, replacement)
+		return nil
 	}
 }
 
 func RemoveInfTransformer() GridTransformer {
-	return func(values *GridValues) {
+	return func(ctx context.Context, values *GridValues) error {
 		for i := 0; i < len(values.Values); i++ {
 			if math.IsInf(values.Values[i], 0) {
 				values.Values[i] = math.NaN()
 			}
 		}
+		return nil
+	}
+}
+
+func BilateralTransformer(sigma, color float64, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		filtered, err := transformers.BilateralFilter(ctx, values.Values, values.SizeX, values.SizeY, sigma, color, opts...)
+		if err != nil {
+			return fmt.Errorf("error applying bilateral filter: %w", err)
+		}
+		values.Values = filtered
+		return nil
 	}
 }
 
-func BilateralTransformer(sigma, color float64) GridTransformer {
-	return func(values *GridValues) {
-		values.Values = transformers.BilateralFilter(values.Values, values.SizeX, values.SizeY, sigma, color)
+// SpeckleTransformer removes connected regions of s.Member pixels smaller
+// than s.MinArea pixels (see transformers.RemoveSpeckles).
+func SpeckleTransformer(s transformers.Speckle, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		cleaned, err := transformers.RemoveSpeckles(ctx, values.Values, values.SizeX, values.SizeY, s, opts...)
+		if err != nil {
+			return fmt.Errorf("error removing speckles: %w", err)
+		}
+		values.Values = cleaned
+		return nil
+	}
+}
+
+// HysteresisTransformer keeps weak pixels only where they are connected to a
+// strong one (see transformers.HysteresisThreshold).
+func HysteresisTransformer(h transformers.Hysteresis, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		masked, err := transformers.HysteresisThreshold(ctx, values.Values, values.SizeX, values.SizeY, h, opts...)
+		if err != nil {
+			return fmt.Errorf("error applying hysteresis threshold: %w", err)
+		}
+		values.Values = masked
+		return nil
+	}
+}
+
+// GapFillTransformer fills NaN gaps up to g.MaxArea from the valid values
+// around them (see transformers.FillGaps).
+func GapFillTransformer(g transformers.GapFill, opts ...transformers.Options) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		filled, err := transformers.FillGaps(ctx, values.Values, values.SizeX, values.SizeY, g, opts...)
+		if err != nil {
+			return fmt.Errorf("error filling gaps: %w", err)
+		}
+		values.Values = filled
+		return nil
 	}
 }
@@ -1,9 +1,13 @@
 package grid_to_isobands_test
 
 import (
+	"context"
+	"errors"
+	"math"
 	"reflect"
 	"testing"
 
+	"github.com/paulmach/orb"
 	"github.com/skysparq/grid-to-isobands"
 )
 
@@ -25,7 +29,10 @@ func TestSwapEvenSizedRows(t *testing.T) {
 		},
 	}
 	transform := grid_to_isobands.SwapRightAndLeftTransformer()
-	transform(values)
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
 	expected := &grid_to_isobands.GridValues{
 		SizeX: 10,
 		SizeY: 2,
@@ -65,7 +72,10 @@ func TestSwapOddSizedRows(t *testing.T) {
 		},
 	}
 	transform := grid_to_isobands.SwapRightAndLeftTransformer()
-	transform(values)
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
 	expected := &grid_to_isobands.GridValues{
 		SizeX: 5,
 		SizeY: 2,
@@ -108,7 +118,10 @@ func TestReverseVerticalOdd(t *testing.T) {
 		},
 	}
 	transform := grid_to_isobands.ReverseVerticalTransformer()
-	transform(values)
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
 	expected := &grid_to_isobands.GridValues{
 		SizeX: 5,
 		SizeY: 3,
@@ -157,7 +170,10 @@ func TestReverseVerticalEven(t *testing.T) {
 		},
 	}
 	transform := grid_to_isobands.ReverseVerticalTransformer()
-	transform(values)
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
 	expected := &grid_to_isobands.GridValues{
 		SizeX: 3,
 		SizeY: 4,
@@ -184,3 +200,225 @@ func TestReverseVerticalEven(t *testing.T) {
 		t.Fatalf(`expected %v, got %v`, expected, values)
 	}
 }
+
+func TestCrop(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX: 4,
+		SizeY: 3,
+		Values: []float64{
+			1, 2, 3, 4,
+			5, 6, 7, 8,
+			9, 10, 11, 12,
+		},
+		Lats: []float64{
+			10, 10, 10, 10,
+			11, 11, 11, 11,
+			12, 12, 12, 12,
+		},
+		Lons: []float64{
+			350, 351, 352, 353,
+			350, 351, 352, 353,
+			350, 351, 352, 353,
+		},
+	}
+	transform := grid_to_isobands.CropTransformer(orb.Bound{Min: orb.Point{-9.5, 10.5}, Max: orb.Point{-7.5, 12}})
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	expected := &grid_to_isobands.GridValues{
+		SizeX: 2,
+		SizeY: 2,
+		Values: []float64{
+			6, 7,
+			10, 11,
+		},
+		Lats: []float64{
+			11, 11,
+			12, 12,
+		},
+		Lons: []float64{
+			351, 352,
+			351, 352,
+		},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, values)
+	}
+}
+
+func TestCropOutsideGrid(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:  2,
+		SizeY:  1,
+		Values: []float64{1, 2},
+		Lats:   []float64{10, 10},
+		Lons:   []float64{20, 21},
+	}
+	transform := grid_to_isobands.CropTransformer(orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{1, 1}})
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if values.SizeX != 2 || values.SizeY != 1 {
+		t.Fatalf(`expected grid size to be unchanged, got %vx%v`, values.SizeX, values.SizeY)
+	}
+	for _, v := range values.Values {
+		if !math.IsNaN(v) {
+			t.Fatalf(`expected all values to be NaN, got %v`, values.Values)
+		}
+	}
+}
+
+func TestCropAcrossGridSeam(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX: 12,
+		SizeY: 2,
+		Values: []float64{
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+			12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23,
+		},
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 0, Lon0: 0, DLat: 1, DLon: 30},
+	}
+	transform := grid_to_isobands.CropTransformer(orb.Bound{Min: orb.Point{-40, 0}, Max: orb.Point{40, 1}})
+	err := transform(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	expected := &grid_to_isobands.GridValues{
+		SizeX: 3,
+		SizeY: 2,
+		Values: []float64{
+			11, 0, 1,
+			23, 12, 13,
+		},
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 0, Lon0: -30, DLat: 1, DLon: 30},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, values)
+	}
+}
+
+func TestCropAcrossAntimeridian(t *testing.T) {
+	lons := []float64{-180, -150, -120, -90, -60, -30, 0, 30, 60, 90, 120, 150}
+	bound := orb.Bound{Min: orb.Point{140, 0}, Max: orb.Point{-140, 0}}
+
+	values := &grid_to_isobands.GridValues{
+		SizeX:    12,
+		SizeY:    1,
+		Values:   []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		Geometry: grid_to_isobands.LatLonAxes{Lats: []float64{0}, Lons: lons},
+	}
+	err := grid_to_isobands.CropTransformer(bound)(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	expected := &grid_to_isobands.GridValues{
+		SizeX:    3,
+		SizeY:    1,
+		Values:   []float64{11, 0, 1},
+		Geometry: grid_to_isobands.LatLonAxes{Lats: []float64{0}, Lons: []float64{150, 180, 210}},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf(`expected %v, got %v`, expected, values)
+	}
+
+	values = &grid_to_isobands.GridValues{
+		SizeX:  4,
+		SizeY:  1,
+		Values: []float64{1, 2, 3, 4},
+		Lats:   []float64{0, 0, 0, 0},
+		Lons:   []float64{120, 150, 180, 210},
+	}
+	err = grid_to_isobands.CropTransformer(bound)(context.Background(), values)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if !reflect.DeepEqual(values.Values, []float64{2, 3, 4}) || !reflect.DeepEqual(values.Lons, []float64{150, 180, 210}) {
+		t.Fatalf(`expected values [2 3 4] at [150 180 210], got %v at %v`, values.Values, values.Lons)
+	}
+}
+
+func TestCropAcrossEdgesOfRegionalGrid(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:    4,
+		SizeY:    1,
+		Values:   []float64{1, 2, 3, 4},
+		Geometry: grid_to_isobands.RegularLatLon{Lat0: 0, Lon0: 0, DLon: 100},
+	}
+	transform := grid_to_isobands.CropTransformer(orb.Bound{Min: orb.Point{-70, 0}, Max: orb.Point{10, 0}})
+	err := transform(context.Background(), values)
+	if err == nil {
+		t.Fatalf(`expected an error, got %v`, values)
+	}
+}
+
+func TestPreprocessErrorIdentifiesStep(t *testing.T) {
+	args := &grid_to_isobands.IsobandArgs{
+		Grid: &grid_to_isobands.GridValues{
+			SizeX:    3,
+			SizeY:    3,
+			Values:   make([]float64, 9),
+			Geometry: grid_to_isobands.RegularLatLon{Lat0: 10, Lon0: 10, DLat: 1, DLon: 1},
+		},
+		Preprocesses: []grid_to_isobands.GridTransformer{
+			grid_to_isobands.RemoveInfTransformer(),
+			grid_to_isobands.GaussianTransformer(4, 1),
+		},
+		Step: 1,
+	}
+	_, err := grid_to_isobands.IsobandsFromGrid(context.Background(), args)
+	var preprocessErr *grid_to_isobands.PreprocessError
+	if !errors.As(err, &preprocessErr) {
+		t.Fatalf(`expected a *PreprocessError, got %v`, err)
+	}
+	if preprocessErr.Step != 1 {
+		t.Fatalf(`expected step 1, got %v`, preprocessErr.Step)
+	}
+}
+
+func TestMedianTransformerReportsError(t *testing.T) {
+	values := &grid_to_isobands.GridValues{SizeX: 2, SizeY: 2, Values: []float64{1, 2, 3, 4}}
+	err := grid_to_isobands.MedianTransformer(2)(context.Background(), values)
+	if err == nil {
+		t.Fatalf(`expected an error for an even kernel`)
+	}
+	if !reflect.DeepEqual(values.Values, []float64{1, 2, 3, 4}) {
+		t.Fatalf(`expected values to be unchanged, got %v`, values.Values)
+	}
+}
+
+func TestTransformersStopWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transforms := map[string]grid_to_isobands.GridTransformer{
+		`gaussian`:   grid_to_isobands.GaussianTransformer(3, 1),
+		`median`:     grid_to_isobands.MedianTransformer(3),
+		`bilateral`:  grid_to_isobands.BilateralTransformer(1, 5),
+		`open-close`: grid_to_isobands.OpenCloseTransformer(3),
+	}
+	for name, transform := range transforms {
+		t.Run(name, func(t *testing.T) {
+			values := &grid_to_isobands.GridValues{SizeX: 3, SizeY: 3, Values: make([]float64, 9)}
+			err := transform(ctx, values)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf(`expected %v, got %v`, context.Canceled, err)
+			}
+		})
+	}
+
+	args := &grid_to_isobands.IsobandArgs{
+		Grid: &grid_to_isobands.GridValues{
+			SizeX:    3,
+			SizeY:    3,
+			Values:   make([]float64, 9),
+			Geometry: grid_to_isobands.RegularLatLon{Lat0: 10, Lon0: 10, DLat: 1, DLon: 1},
+		},
+		Preprocesses: []grid_to_isobands.GridTransformer{grid_to_isobands.RemoveInfTransformer()},
+		Step:         1,
+	}
+	_, err := grid_to_isobands.IsobandsFromGrid(ctx, args)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf(`expected %v, got %v`, context.Canceled, err)
+	}
+}
@@ -1,6 +1,8 @@
 package transformers
 
 import (
+	"context"
+	"fmt"
 	"math"
 )
 
@@ -9,10 +11,25 @@ import (
 // Optimizations:
 //  1. Spatial kernel fully precomputed (eliminates radius^2 * W * H Exp calls)
 //  2. Range kernel approximated via LUT keyed on discretized dBZ difference
-//  3. Interior pixels processed without bounds checks
-func BilateralFilter(data []float64, width, height int, sigma, color float64) []float64 {
-	if len(data) != width*height {
-		panic("bilateral: data length does not match width*height")
+//  3. Interior pixels processed without boundary handling
+//
+// NaN neighbors are ignored. A NaN pixel is filled from its valid neighbors
+// with spatial weights alone, since it has no value to compare them to, and
+// stays NaN if it has none (see Options.PreserveNaN to keep it NaN).
+//
+// Neighbors beyond the edges are read as opts.Boundary says, and ignored by
+// default, as with BoundaryNaN. Rows are split across the workers set in
+// opts, and it stops with ctx.Err() if ctx is cancelled.
+func BilateralFilter(ctx context.Context, data []float64, width, height int, sigma, color float64, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if !(sigma > 0) || !(color > 0) {
+		return nil, fmt.Errorf("sigma and color must be positive, got %v and %v", sigma, color)
+	}
+	o := options(opts)
+	if err := o.Boundary.check(); err != nil {
+		return nil, err
 	}
 
 	radius := int(math.Ceil(3 * sigma))
@@ -39,19 +56,23 @@ func BilateralFilter(data []float64, width, height int, sigma, color float64) []
 		rangeLUT[i] = math.Exp(-(diff * diff) / twoSigmaRangeSq)
 	}
 	rangeLUTLookup := func(diff float64) float64 {
-		idx := int(math.Abs(diff) / rangeStep)
-		if idx >= lutSize {
+		scaled := math.Abs(diff) / rangeStep
+		if !(scaled < lutSize) {
 			return 0
 		}
-		return rangeLUT[idx]
+		return rangeLUT[int(scaled)]
 	}
 
+	boundary := o.Boundary.or(BoundaryNaN)
+	srcCols := boundary.columns(width, radius)
+	srcRows := boundary.rows(height, radius)
 	result := make([]float64, len(data))
 
-	for y := 0; y < height; y++ {
+	err := parallelRows(ctx, height, o, func(y int) {
 		interiorRow := y >= radius && y < height-radius
 		for x := 0; x < width; x++ {
 			centerVal := data[y*width+x]
+			centerMissing := math.IsNaN(centerVal)
 			var sum, weightSum float64
 
 			if interiorRow && x >= radius && x < width-radius {
@@ -60,25 +81,37 @@ func BilateralFilter(data []float64, width, height int, sigma, color float64) []
 					kRowBase := (dy + radius) * windowSize
 					for dx := -radius; dx <= radius; dx++ {
 						nVal := data[rowBase+dx]
-						w := spatialKernel[kRowBase+(dx+radius)] * rangeLUTLookup(centerVal-nVal)
+						if math.IsNaN(nVal) {
+							continue
+						}
+						w := spatialKernel[kRowBase+(dx+radius)]
+						if !centerMissing {
+							w *= rangeLUTLookup(centerVal - nVal)
+						}
 						sum += w * nVal
 						weightSum += w
 					}
 				}
 			} else {
 				for dy := -radius; dy <= radius; dy++ {
-					ny := y + dy
-					if ny < 0 || ny >= height {
+					ny := srcRows[y+dy+radius]
+					if ny < 0 {
 						continue
 					}
 					kRowBase := (dy + radius) * windowSize
 					for dx := -radius; dx <= radius; dx++ {
-						nx := x + dx
-						if nx < 0 || nx >= width {
+						nx := srcCols[x+dx+radius]
+						if nx < 0 {
 							continue
 						}
 						nVal := data[ny*width+nx]
-						w := spatialKernel[kRowBase+(dx+radius)] * rangeLUTLookup(centerVal-nVal)
+						if math.IsNaN(nVal) {
+							continue
+						}
+						w := spatialKernel[kRowBase+(dx+radius)]
+						if !centerMissing {
+							w *= rangeLUTLookup(centerVal - nVal)
+						}
 						sum += w * nVal
 						weightSum += w
 					}
@@ -91,7 +124,11 @@ func BilateralFilter(data []float64, width, height int, sigma, color float64) []
 				result[y*width+x] = centerVal
 			}
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result
+	preserveNaN(data, result, o)
+	return result, nil
 }
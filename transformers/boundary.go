@@ -0,0 +1,112 @@
+package transformers
+
+import "fmt"
+
+// Boundary says what a filter reads for neighbors beyond the edges of the
+// grid.
+type Boundary int
+
+const (
+	// BoundaryDefault uses each filter's own rule: BilateralFilter ignores
+	// neighbors beyond the edges, like BoundaryNaN, and the other filters
+	// clamp.
+	BoundaryDefault Boundary = iota
+	// BoundaryClamp repeats the nearest edge pixel.
+	BoundaryClamp
+	// BoundaryReflect mirrors the grid about its edge pixels, so the column
+	// left of column 0 is column 1.
+	BoundaryReflect
+	// BoundaryWrapX wraps columns around, so the column left of column 0 is
+	// the last one, and clamps rows. It suits global grids whose columns
+	// span all 360 degrees of longitude, where it avoids a seam at the
+	// dateline.
+	BoundaryWrapX
+	// BoundaryNaN treats everything beyond the edges as missing, like NaN.
+	BoundaryNaN
+)
+
+func (b Boundary) String() string {
+	switch b {
+	case BoundaryDefault:
+		return "default"
+	case BoundaryClamp:
+		return "clamp"
+	case BoundaryReflect:
+		return "reflect"
+	case BoundaryWrapX:
+		return "wrap-x"
+	case BoundaryNaN:
+		return "nan"
+	default:
+		return fmt.Sprintf("Boundary(%d)", int(b))
+	}
+}
+
+func (b Boundary) check() error {
+	if b < BoundaryDefault || b > BoundaryNaN {
+		return fmt.Errorf("unknown boundary mode %v", b)
+	}
+	return nil
+}
+
+// columns returns the column a filter reads for each column x from -pad to
+// width+pad-1 of a grid, at index x+pad, or -1 where it reads missing data.
+func (b Boundary) columns(width, pad int) []int {
+	return b.sources(width, pad, true)
+}
+
+// rows returns the row a filter reads for each row y from -pad to
+// height+pad-1 of a grid, at index y+pad, or -1 where it reads missing data.
+func (b Boundary) rows(height, pad int) []int {
+	return b.sources(height, pad, false)
+}
+
+// sources maps the indices from -pad to n+pad-1 onto the samples of a line of
+// n samples they read. periodic says whether the line is one BoundaryWrapX
+// wraps.
+func (b Boundary) sources(n, pad int, periodic bool) []int {
+	sources := make([]int, n+2*pad)
+	for k := range sources {
+		sources[k] = b.source(k-pad, n, periodic)
+	}
+	return sources
+}
+
+// or returns b, or fallback for BoundaryDefault.
+func (b Boundary) or(fallback Boundary) Boundary {
+	if b == BoundaryDefault {
+		return fallback
+	}
+	return b
+}
+
+func (b Boundary) source(i, n int, periodic bool) int {
+	switch {
+	case i >= 0 && i < n:
+		return i
+	case b == BoundaryNaN || n == 0:
+		return -1
+	case b == BoundaryWrapX && periodic:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	case b == BoundaryReflect:
+		if n == 1 {
+			return 0
+		}
+		// Mirroring repeats every 2(n-1) samples.
+		period := 2 * (n - 1)
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		return i
+	default:
+		return clamp(i, 0, n-1)
+	}
+}
@@ -0,0 +1,169 @@
+package transformers_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+var boundaries = []transformers.Boundary{
+	transformers.BoundaryClamp,
+	transformers.BoundaryReflect,
+	transformers.BoundaryWrapX,
+	transformers.BoundaryNaN,
+}
+
+// neighbor returns the value a filter reads at (x, y) under b, which may lie
+// beyond the edges of the grid, reflecting back and forth as often as it
+// takes.
+func neighbor(data []float64, width, height, x, y int, b transformers.Boundary) float64 {
+	x, ok := edge(x, width, b, true)
+	if !ok {
+		return math.NaN()
+	}
+	y, ok = edge(y, height, b, false)
+	if !ok {
+		return math.NaN()
+	}
+	return data[y*width+x]
+}
+
+func edge(i, n int, b transformers.Boundary, periodic bool) (int, bool) {
+	for i < 0 || i >= n {
+		switch {
+		case b == transformers.BoundaryNaN:
+			return 0, false
+		case b == transformers.BoundaryWrapX && periodic:
+			i = (i%n + n) % n
+		case b == transformers.BoundaryReflect && n == 1:
+			i = 0
+		case b == transformers.BoundaryReflect && i < 0:
+			i = -i
+		case b == transformers.BoundaryReflect:
+			i = 2*(n-1) - i
+		default:
+			i = min(max(i, 0), n-1)
+		}
+	}
+	return i, true
+}
+
+func TestBoundaryModesMatchDirectScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(7, 8))
+	for _, size := range [][2]int{{1, 1}, {2, 3}, {6, 5}, {17, 13}} {
+		width, height := size[0], size[1]
+		quantized := make([]float64, width*height)
+		continuous := make([]float64, width*height)
+		for i := range quantized {
+			quantized[i] = float64(r.IntN(8))
+			continuous[i] = r.NormFloat64()
+			if r.IntN(10) == 0 {
+				quantized[i], continuous[i] = math.NaN(), math.NaN()
+			}
+		}
+		for _, b := range boundaries {
+			for _, kernel := range []int{3, 5, 7, 9} {
+				t.Run(fmt.Sprintf(`%v/%dx%d/kernel=%d`, b, width, height, kernel), func(t *testing.T) {
+					opts := transformers.Options{Boundary: b}
+					ops := transformers.NewMorphologicalOps(width, height, opts)
+					eroded, err := ops.Erode(context.Background(), continuous, kernel)
+					if err != nil {
+						t.Fatalf(`expected no error, got %v`, err)
+					}
+					assertIdentical(t, eroded, directExtreme(continuous, width, height, kernel, true, b))
+					dilated, err := ops.Dilate(context.Background(), continuous, kernel)
+					if err != nil {
+						t.Fatalf(`expected no error, got %v`, err)
+					}
+					assertIdentical(t, dilated, directExtreme(continuous, width, height, kernel, false, b))
+					for _, data := range [][]float64{quantized, continuous} {
+						median, err := transformers.MedianFilter(context.Background(), data, width, height, kernel, opts)
+						if err != nil {
+							t.Fatalf(`expected no error, got %v`, err)
+						}
+						assertIdentical(t, median, directMedian(data, width, height, kernel, b))
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestWrapXHasNoSeam(t *testing.T) {
+	width, height, shift := 40, 11, 13
+	data := reflectivityGrid(width, height)
+	// Rolling the columns of a periodic grid moves its seam, so the result
+	// must be the same up to the roll.
+	rolled := make([]float64, len(data))
+	for y := range height {
+		for x := range width {
+			rolled[y*width+(x+shift)%width] = data[y*width+x]
+		}
+	}
+	opts := transformers.Options{Boundary: transformers.BoundaryWrapX}
+	for name, apply := range filters {
+		t.Run(name, func(t *testing.T) {
+			expected, err := apply(context.Background(), data, width, height, opts)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			actual, err := apply(context.Background(), rolled, width, height, opts)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			for y := range height {
+				for x := range width {
+					e, a := expected[y*width+x], actual[y*width+(x+shift)%width]
+					if math.IsNaN(e) != math.IsNaN(a) || math.Abs(e-a) > 1e-9 {
+						t.Fatalf(`expected %v at (%d, %d), got %v`, e, x, y, a)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownBoundary(t *testing.T) {
+	data, width, height := gapGrid()
+	for name, apply := range filters {
+		t.Run(name, func(t *testing.T) {
+			_, err := apply(context.Background(), data, width, height, transformers.Options{Boundary: 99})
+			if err == nil {
+				t.Fatalf(`expected an error, got nil`)
+			}
+		})
+	}
+}
+
+func TestBilateralDefaultBoundaryIgnoresEdges(t *testing.T) {
+	data := []float64{
+		10, 12, 30, 31, 5,
+		11, 14, 29, 35, 7,
+		9, 20, 25, 40, 12,
+		8, 22, 21, 38, 15,
+	}
+	// The output of BilateralFilter before boundary modes were added, when it
+	// skipped neighbors beyond the edges. With sigma 1 every pixel of the grid
+	// is within the radius of an edge.
+	expected := []float64{
+		11.543913656040399, 13.593907680246707, 28.729899075966383, 30.6789781960176, 6.7560027267253275,
+		12.081449343753738, 15.301003799391522, 27.80987021308634, 32.43766230685231, 8.766696133536918,
+		11.906016950073264, 18.98427791000828, 24.71654847247468, 35.33720488733891, 12.656593066145552,
+		11.436587918141145, 20.150826820821255, 22.32091893483326, 35.03225892592854, 15.217190450962363,
+	}
+	for _, opts := range [][]transformers.Options{nil, {{}}, {{Boundary: transformers.BoundaryNaN}}} {
+		actual, err := transformers.BilateralFilter(context.Background(), data, 5, 4, 1, 10, opts...)
+		if err != nil {
+			t.Fatalf(`expected no error, got %v`, err)
+		}
+		for i := range expected {
+			if math.Abs(actual[i]-expected[i]) > 1e-12 {
+				t.Fatalf(`expected %v, got %v`, expected, actual)
+			}
+		}
+	}
+}
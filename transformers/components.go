@@ -0,0 +1,192 @@
+package transformers
+
+import (
+	"context"
+	"fmt"
+)
+
+// Connectivity is which neighbors of a pixel count as touching it.
+type Connectivity int
+
+const (
+	// Connect4 joins pixels that share an edge.
+	Connect4 Connectivity = 4
+	// Connect8 also joins pixels that touch at a corner.
+	Connect8 Connectivity = 8
+)
+
+// LabelComponents numbers the connected regions of pixels where member is
+// true from 1 up, returning the label of every pixel, 0 outside all regions,
+// and the number of regions. With BoundaryWrapX in opts, regions touching the
+// left and right edges join up, as on a global grid; other boundary modes
+// don't join anything. It stops with ctx.Err() if ctx is cancelled.
+func LabelComponents(ctx context.Context, member []bool, width, height int, connectivity Connectivity, opts ...Options) ([]int32, int, error) {
+	if width <= 0 || height <= 0 || len(member) != width*height {
+		return nil, 0, fmt.Errorf("mask length %d does not match width*height (%d*%d=%d)", len(member), width, height, width*height)
+	}
+	if connectivity != Connect4 && connectivity != Connect8 {
+		return nil, 0, fmt.Errorf("connectivity must be 4 or 8, got %d", connectivity)
+	}
+	o := options(opts)
+	if err := o.Boundary.check(); err != nil {
+		return nil, 0, err
+	}
+
+	// Label pixels from their neighbors above and to the left, recording
+	// which provisional labels meet in a union-find forest.
+	labels := make([]int32, len(member))
+	forest := unionFind{0}
+	join := func(i, j int) {
+		if labels[j] != 0 {
+			forest.union(labels[i], labels[j])
+		}
+	}
+	for y := 0; y < height; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if !member[i] {
+				continue
+			}
+			labels[i] = forest.add()
+			if x > 0 {
+				join(i, i-1)
+			}
+			if y > 0 {
+				join(i, i-width)
+				if connectivity == Connect8 && x > 0 {
+					join(i, i-width-1)
+				}
+				if connectivity == Connect8 && x < width-1 {
+					join(i, i-width+1)
+				}
+			}
+		}
+	}
+	if o.Boundary == BoundaryWrapX && width > 1 {
+		for y := 0; y < height; y++ {
+			first, last := y*width, y*width+width-1
+			if labels[first] == 0 {
+				continue
+			}
+			join(first, last)
+			if connectivity == Connect8 && y > 0 {
+				join(first, last-width)
+			}
+			if connectivity == Connect8 && y < height-1 {
+				join(first, last+width)
+			}
+		}
+	}
+
+	// Renumber the roots of the forest in order of first appearance.
+	final := make([]int32, len(forest))
+	count := 0
+	for i, label := range labels {
+		if label == 0 {
+			continue
+		}
+		root := forest.find(label)
+		if final[root] == 0 {
+			count++
+			final[root] = int32(count)
+		}
+		labels[i] = final[root]
+	}
+	return labels, count, nil
+}
+
+// unionFind is a disjoint-set forest of labels, each holding its parent.
+// Entry 0 is unused so labels can start at 1.
+type unionFind []int32
+
+func (u *unionFind) add() int32 {
+	label := int32(len(*u))
+	*u = append(*u, label)
+	return label
+}
+
+func (u unionFind) find(label int32) int32 {
+	for u[label] != label {
+		// Path halving keeps the trees shallow.
+		u[label] = u[u[label]]
+		label = u[label]
+	}
+	return label
+}
+
+// union joins the sets of a and b.
+func (u unionFind) union(a, b int32) {
+	a, b = u.find(a), u.find(b)
+	if a < b {
+		u[b] = a
+	} else {
+		u[a] = b
+	}
+}
+
+// Speckle says which regions RemoveSpeckles removes.
+type Speckle struct {
+	// Member picks the pixels regions are made of, e.g. GreaterThan(20).
+	Member ThresholdFunc
+	// MinArea is the smallest area a region keeps.
+	MinArea float64
+	// RowAreas holds the area of a pixel in each row, in the unit of MinArea.
+	// When nil every pixel has an area of 1, so MinArea counts pixels.
+	RowAreas []float64
+	// Connectivity is which neighbors join into a region; zero means Connect8.
+	Connectivity Connectivity
+	// Replacement is what the pixels of removed regions are set to, e.g. NaN
+	// or a floor value.
+	Replacement float64
+}
+
+// RemoveSpeckles returns a copy of data with every connected region of
+// s.Member pixels smaller than s.MinArea set to s.Replacement, which clears
+// isolated clutter without eroding the edges of larger regions the way
+// morphological opening does. opts sets the boundary mode (see
+// LabelComponents).
+func RemoveSpeckles(ctx context.Context, data []float64, width, height int, s Speckle, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if s.Member == nil {
+		return nil, fmt.Errorf("speckle member predicate is required")
+	}
+	if s.RowAreas != nil && len(s.RowAreas) != height {
+		return nil, fmt.Errorf("got %d row areas for %d rows", len(s.RowAreas), height)
+	}
+	connectivity := s.Connectivity
+	if connectivity == 0 {
+		connectivity = Connect8
+	}
+
+	member := make([]bool, len(data))
+	for i, v := range data {
+		member[i] = s.Member(v)
+	}
+	labels, count, err := LabelComponents(ctx, member, width, height, connectivity, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	areas := make([]float64, count+1)
+	for i, label := range labels {
+		if s.RowAreas == nil {
+			areas[label]++
+		} else {
+			areas[label] += s.RowAreas[i/width]
+		}
+	}
+	result := make([]float64, len(data))
+	for i, label := range labels {
+		if label != 0 && areas[label] < s.MinArea {
+			result[i] = s.Replacement
+		} else {
+			result[i] = data[i]
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package transformers_test
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// diagonalMask has a region of two pixels touching only at a corner, a
+// region of one pixel on the right edge and one on the left edge.
+var diagonalMask = []bool{
+	true, false, false, false, false,
+	false, true, false, false, false,
+	true, false, false, false, true,
+}
+
+func TestLabelComponents(t *testing.T) {
+	tests := []struct {
+		name         string
+		connectivity transformers.Connectivity
+		boundary     transformers.Boundary
+		expected     []int32
+	}{
+		{
+			name:         `4-connected`,
+			connectivity: transformers.Connect4,
+			expected: []int32{
+				1, 0, 0, 0, 0,
+				0, 2, 0, 0, 0,
+				3, 0, 0, 0, 4,
+			},
+		},
+		{
+			name:         `8-connected`,
+			connectivity: transformers.Connect8,
+			expected: []int32{
+				1, 0, 0, 0, 0,
+				0, 1, 0, 0, 0,
+				1, 0, 0, 0, 2,
+			},
+		},
+		{
+			name:         `4-connected wrapping`,
+			connectivity: transformers.Connect4,
+			boundary:     transformers.BoundaryWrapX,
+			expected: []int32{
+				1, 0, 0, 0, 0,
+				0, 2, 0, 0, 0,
+				3, 0, 0, 0, 3,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels, count, err := transformers.LabelComponents(context.Background(), diagonalMask, 5, 3, test.connectivity, transformers.Options{Boundary: test.boundary})
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			if !slices.Equal(labels, test.expected) {
+				t.Fatalf(`expected %v, got %v`, test.expected, labels)
+			}
+			if expected := int(slices.Max(test.expected)); count != expected {
+				t.Fatalf(`expected %v regions, got %v`, expected, count)
+			}
+		})
+	}
+}
+
+func TestLabelComponentsJoinsUShapes(t *testing.T) {
+	// The arms of the U get different provisional labels until the bottom
+	// row joins them.
+	mask := []bool{
+		true, false, true, false, true,
+		true, false, true, false, true,
+		true, true, true, true, true,
+	}
+	labels, count, err := transformers.LabelComponents(context.Background(), mask, 5, 3, transformers.Connect4)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if count != 1 {
+		t.Fatalf(`expected 1 region, got %v: %v`, count, labels)
+	}
+}
+
+func TestRemoveSpeckles(t *testing.T) {
+	data := []float64{
+		30, 0, 0, 0, 0, 0,
+		0, 0, 40, 45, 40, 0,
+		0, 0, 40, 50, 40, 0,
+		0, 0, 0, 0, 0, 35,
+	}
+	speckle := transformers.Speckle{
+		Member:       transformers.GreaterThan(20),
+		MinArea:      3,
+		Connectivity: transformers.Connect4,
+		Replacement:  math.NaN(),
+	}
+	actual, err := transformers.RemoveSpeckles(context.Background(), data, 6, 4, speckle)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	nan := math.NaN()
+	expected := []float64{
+		nan, 0, 0, 0, 0, 0,
+		0, 0, 40, 45, 40, 0,
+		0, 0, 40, 50, 40, 0,
+		0, 0, 0, 0, 0, nan,
+	}
+	assertIdentical(t, actual, expected)
+
+	// Weighting the bottom row up keeps its single pixel.
+	speckle.RowAreas = []float64{1, 1, 1, 5}
+	actual, err = transformers.RemoveSpeckles(context.Background(), data, 6, 4, speckle)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if actual[23] != 35 {
+		t.Fatalf(`expected 35, got %v`, actual[23])
+	}
+}
@@ -0,0 +1,24 @@
+package transformers
+
+// Window is a rectangular sub-grid in grid index space. X and Y are the
+// column and row of its first cell.
+type Window struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// CropGrid copies the cells inside window out of a flat row-major grid of the
+// given width into a new, smaller grid of window.Width columns. Columns past
+// the right edge wrap around to the left one, so a window can span the seam
+// of a grid that goes all the way around the globe.
+func CropGrid(data []float64, width int, window Window) []float64 {
+	result := make([]float64, window.Width*window.Height)
+	for y := 0; y < window.Height; y++ {
+		row := data[(window.Y+y)*width : (window.Y+y+1)*width]
+		n := copy(result[y*window.Width:(y+1)*window.Width], row[window.X:])
+		copy(result[y*window.Width+n:(y+1)*window.Width], row)
+	}
+	return result
+}
@@ -0,0 +1,320 @@
+package transformers
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Three ways to compute a row of MedianFilter output, all giving exactly the
+// value sorting the window's valid values and taking the middle one would:
+//
+//   - sortedMedianRow does just that, and handles anything.
+//   - networkMedianRow runs a fixed median selection network over 3x3 and
+//     5x5 windows, which avoids sort's branching and allocation.
+//   - histogramMedianRow keeps a histogram of the window as it slides along
+//     the row (Huang's algorithm), which only works on data with few distinct
+//     values, like reflectivity in 0.5 dBZ steps, but costs O(kernelSize)
+//     rather than O(kernelSize² log kernelSize) per pixel.
+//
+// medianRow picks between them. All of them read windows of any Footprint
+// except the networks, which need a square one.
+
+// histogramMinKernel is the smallest kernel the histogram method is used for;
+// 3x3 windows are quicker to run through median9.
+const histogramMinKernel = 5
+
+// histogramMaxLevels caps how many distinct values the histogram method
+// tracks, keeping its histogram small enough to stay in cache.
+const histogramMaxLevels = 4096
+
+// noLevel marks NaN in the levels quantize assigns.
+const noLevel = math.MaxUint16
+
+// medianRow returns a function filling row y of output with the median of
+// every window of data in footprint f, reading beyond the edges as b says
+// and choosing the fastest method that applies.
+func medianRow(data, output []float64, width, height int, f Footprint, b Boundary) func(y int) {
+	w := newMedianWindows(width, height, f, b)
+	if slices.ContainsFunc(data, isNegativeZero) {
+		// Sorting doesn't order -0 and +0, so when both are present only
+		// sorting knows which one it returns.
+		return sortedMedianRow(data, output, w)
+	}
+	half, square := f.square()
+	if !square || 2*half+1 >= histogramMinKernel {
+		if values, levels, ok := quantize(data); ok {
+			return histogramMedianRow(values, levels, output, w)
+		}
+	}
+	switch {
+	case square && half == 1:
+		return networkMedianRow(data, output, w, median9[:])
+	case square && half == 2:
+		return networkMedianRow(data, output, w, median25[:])
+	}
+	return sortedMedianRow(data, output, w)
+}
+
+func isNegativeZero(v float64) bool {
+	return v == 0 && math.Signbit(v)
+}
+
+// medianWindows lays out the windows the median methods read.
+type medianWindows struct {
+	width     int
+	footprint Footprint
+	// columns and rows are the columns and rows the windows read, as
+	// Boundary.sources lists them, padded by the widest half-width and the
+	// half-height.
+	columns, rows []int
+	pad           int
+}
+
+func newMedianWindows(width, height int, f Footprint, b Boundary) medianWindows {
+	pad := f.maxHalfWidth()
+	return medianWindows{
+		width:     width,
+		footprint: f,
+		columns:   b.columns(width, pad),
+		rows:      b.rows(height, f.HalfHeight),
+		pad:       pad,
+	}
+}
+
+// rowsAround returns the rows the window centered on row y reads.
+func (w medianWindows) rowsAround(y int) []int {
+	return w.rows[y : y+2*w.footprint.HalfHeight+1]
+}
+
+// columnsAround returns the columns the window centered on column x reads
+// in grid row ny.
+func (w medianWindows) columnsAround(x, ny int) []int {
+	half := w.footprint.HalfWidths[ny]
+	return w.columns[w.pad+x-half : w.pad+x+half+1]
+}
+
+// sortedMedianRow sorts the valid values of every window. A window with none
+// gives NaN.
+func sortedMedianRow(data, output []float64, w medianWindows) func(y int) {
+	width := w.width
+	return func(y int) {
+		var window []float64
+		for x := 0; x < width; x++ {
+			window = window[:0]
+			for _, ny := range w.rowsAround(y) {
+				if ny < 0 {
+					continue
+				}
+				for _, nx := range w.columnsAround(x, ny) {
+					if nx < 0 {
+						continue
+					}
+					if v := data[ny*width+nx]; !math.IsNaN(v) {
+						window = append(window, v)
+					}
+				}
+			}
+			output[y*width+x] = sortedMedian(window)
+		}
+	}
+}
+
+func sortedMedian(window []float64) float64 {
+	if len(window) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(window)
+	return window[len(window)/2]
+}
+
+// median9 and median25 are the median selection networks for 3x3 and 5x5
+// windows from N. Devillard, "Fast median search: an ANSI C implementation"
+// (1998). After the compare-exchanges the middle element holds the median.
+var (
+	median9 = [...][2]uint8{
+		{1, 2}, {4, 5}, {7, 8}, {0, 1}, {3, 4}, {6, 7}, {1, 2}, {4, 5}, {7, 8},
+		{0, 3}, {5, 8}, {4, 7}, {3, 6}, {1, 4}, {2, 5}, {4, 7}, {4, 2}, {6, 4},
+		{4, 2},
+	}
+	median25 = [...][2]uint8{
+		{0, 1}, {3, 4}, {2, 4}, {2, 3}, {6, 7}, {5, 7}, {5, 6}, {9, 10}, {8, 10},
+		{8, 9}, {12, 13}, {11, 13}, {11, 12}, {15, 16}, {14, 16}, {14, 15},
+		{18, 19}, {17, 19}, {17, 18}, {21, 22}, {20, 22}, {20, 21}, {23, 24},
+		{2, 5}, {3, 6}, {0, 6}, {0, 3}, {4, 7}, {1, 7}, {1, 4}, {11, 14}, {8, 14},
+		{8, 11}, {12, 15}, {9, 15}, {9, 12}, {13, 16}, {10, 16}, {10, 13},
+		{20, 23}, {17, 23}, {17, 20}, {21, 24}, {18, 24}, {18, 21}, {19, 22},
+		{8, 17}, {9, 18}, {0, 18}, {0, 9}, {10, 19}, {1, 19}, {1, 10}, {11, 20},
+		{2, 20}, {2, 11}, {12, 21}, {3, 21}, {3, 12}, {13, 22}, {4, 22}, {4, 13},
+		{14, 23}, {5, 23}, {5, 14}, {15, 24}, {6, 24}, {6, 15}, {7, 16}, {7, 19},
+		{13, 21}, {15, 23}, {7, 13}, {7, 15}, {1, 9}, {3, 11}, {5, 17}, {11, 17},
+		{9, 17}, {4, 10}, {6, 12}, {7, 14}, {4, 6}, {4, 7}, {12, 14}, {10, 14},
+		{6, 7}, {10, 12}, {6, 10}, {6, 17}, {12, 17}, {7, 17}, {7, 10}, {12, 18},
+		{7, 12}, {10, 18}, {12, 20}, {10, 20}, {10, 12},
+	}
+)
+
+// networkMedianRow runs network over every window of a square footprint
+// with no NaN or missing neighbors, and sorts the valid values of the rest.
+func networkMedianRow(data, output []float64, w medianWindows, network [][2]uint8) func(y int) {
+	size := 2*w.footprint.HalfHeight + 1
+	width := w.width
+	return func(y int) {
+		var window [25]float64
+		p := window[:size*size]
+		for x := 0; x < width; x++ {
+			complete := true
+			i := 0
+			for _, ny := range w.rowsAround(y) {
+				for _, nx := range w.columns[x : x+size] {
+					v := math.NaN()
+					if ny >= 0 && nx >= 0 {
+						v = data[ny*width+nx]
+					}
+					complete = complete && !math.IsNaN(v)
+					p[i] = v
+					i++
+				}
+			}
+			if !complete {
+				valid := slices.DeleteFunc(p, math.IsNaN)
+				output[y*width+x] = sortedMedian(valid)
+				continue
+			}
+			for _, c := range network {
+				a, b := p[c[0]], p[c[1]]
+				p[c[0]], p[c[1]] = min(a, b), max(a, b)
+			}
+			output[y*width+x] = p[len(p)/2]
+		}
+	}
+}
+
+// quantize maps data onto its distinct values, returned in ascending order,
+// giving each pixel the index of its value or noLevel for NaN. ok is false if
+// there are more than histogramMaxLevels distinct values.
+func quantize(data []float64) (values []float64, levels []uint16, ok bool) {
+	// Number the values in the order they're first seen, then renumber them
+	// in ascending order once they're all known.
+	seen := make(map[float64]uint16)
+	levels = make([]uint16, len(data))
+	last, lastLevel := math.NaN(), uint16(noLevel)
+	for i, v := range data {
+		if v != last {
+			// NaN never equals last, so it always lands here.
+			last, lastLevel = v, noLevel
+			if !math.IsNaN(v) {
+				level, found := seen[v]
+				if !found {
+					if len(seen) == histogramMaxLevels {
+						return nil, nil, false
+					}
+					level = uint16(len(seen))
+					seen[v] = level
+					values = append(values, v)
+				}
+				lastLevel = level
+			}
+		}
+		levels[i] = lastLevel
+	}
+
+	order := make([]uint16, len(values))
+	for i := range order {
+		order[i] = uint16(i)
+	}
+	slices.SortFunc(order, func(a, b uint16) int {
+		return cmp.Compare(values[a], values[b])
+	})
+	rank := make([]uint16, len(values))
+	sorted := make([]float64, len(values))
+	for i, level := range order {
+		rank[level] = uint16(i)
+		sorted[i] = values[level]
+	}
+	for i, level := range levels {
+		if level != noLevel {
+			levels[i] = rank[level]
+		}
+	}
+	return sorted, levels, true
+}
+
+// histogramMedianRow slides a histogram of levels along row y, updating it
+// with one pixel in and one out of each window row per step and moving the
+// median level only as far as those changes require.
+func histogramMedianRow(values []float64, levels []uint16, output []float64, w medianWindows) func(y int) {
+	width := w.width
+	return func(y int) {
+		// Each window row reads one grid row, starting at offset, across
+		// half columns either side.
+		type windowRow struct{ offset, half int }
+		var windowRows []windowRow
+		for _, ny := range w.rowsAround(y) {
+			if ny >= 0 {
+				windowRows = append(windowRows, windowRow{ny * width, w.footprint.HalfWidths[ny]})
+			}
+		}
+		h := slidingHistogram{hist: make([]int32, len(values))}
+		for _, row := range windowRows {
+			for _, nx := range w.columns[w.pad-row.half : w.pad+row.half+1] {
+				if nx >= 0 {
+					h.update(levels[row.offset+nx], 1)
+				}
+			}
+		}
+		for x := 0; x < width; x++ {
+			if x > 0 {
+				for _, row := range windowRows {
+					if nx := w.columns[w.pad+x-row.half-1]; nx >= 0 {
+						h.update(levels[row.offset+nx], -1)
+					}
+					if nx := w.columns[w.pad+x+row.half]; nx >= 0 {
+						h.update(levels[row.offset+nx], 1)
+					}
+				}
+			}
+			if h.count == 0 {
+				output[y*width+x] = math.NaN()
+				continue
+			}
+			output[y*width+x] = values[h.middle()]
+		}
+	}
+}
+
+// slidingHistogram counts the levels in a window and tracks its median
+// level.
+type slidingHistogram struct {
+	hist []int32
+	// below is the number of values in the window at levels under median.
+	count, below, median int
+}
+
+// update adds delta values at level to the window.
+func (h *slidingHistogram) update(level uint16, delta int) {
+	if level == noLevel {
+		return
+	}
+	h.hist[level] += int32(delta)
+	h.count += delta
+	if int(level) < h.median {
+		h.below += delta
+	}
+}
+
+// middle moves median to the level of the middle value of a non-empty window
+// and returns it.
+func (h *slidingHistogram) middle() int {
+	target := h.count / 2
+	for h.below > target {
+		h.median--
+		h.below -= int(h.hist[h.median])
+	}
+	for h.below+int(h.hist[h.median]) <= target {
+		h.below += int(h.hist[h.median])
+		h.median++
+	}
+	return h.median
+}
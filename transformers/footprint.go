@@ -0,0 +1,63 @@
+package transformers
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Footprint is a filter window, measured in grid cells, whose width can
+// differ from row to row. It suits grids like regular latitude/longitude
+// ones, whose columns get closer together on the ground towards the poles:
+// giving each row a half-width of the same distance in columns keeps the
+// window the same size on the ground everywhere.
+//
+// The window centered on a pixel spans HalfHeight rows above and below it,
+// and each of those window rows spans HalfWidths[y] columns either side,
+// where y is the grid row it reads.
+type Footprint struct {
+	// HalfWidths holds the half-width in columns of each row of the grid.
+	HalfWidths []int
+	// HalfHeight is the half-height in rows.
+	HalfHeight int
+}
+
+// squareFootprint is the footprint of a square kernelSize window over a grid
+// of the given height.
+func squareFootprint(height, kernelSize int) Footprint {
+	half := kernelSize / 2
+	halfWidths := make([]int, height)
+	for y := range halfWidths {
+		halfWidths[y] = half
+	}
+	return Footprint{HalfWidths: halfWidths, HalfHeight: half}
+}
+
+func (f Footprint) check(height int) error {
+	if len(f.HalfWidths) != height {
+		return fmt.Errorf("footprint has %d half-widths for %d rows", len(f.HalfWidths), height)
+	}
+	if f.HalfHeight < 0 || slices.ContainsFunc(f.HalfWidths, func(half int) bool { return half < 0 }) {
+		return fmt.Errorf("footprint half-widths and half-height must not be negative")
+	}
+	return nil
+}
+
+// maxHalfWidth is the widest half-width of any row, or 0 if there are none.
+func (f Footprint) maxHalfWidth() int {
+	widest := 0
+	for _, half := range f.HalfWidths {
+		widest = max(widest, half)
+	}
+	return widest
+}
+
+// square returns the half-width of f if every row has the same half-width
+// as its half-height.
+func (f Footprint) square() (int, bool) {
+	for _, half := range f.HalfWidths {
+		if half != f.HalfHeight {
+			return 0, false
+		}
+	}
+	return f.HalfHeight, true
+}
@@ -0,0 +1,118 @@
+package transformers_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// directFootprint reduces the valid values of every window of f, giving NaN
+// when a window has none.
+func directFootprint(data []float64, width, height int, f transformers.Footprint, b transformers.Boundary, reduce func([]float64) float64) []float64 {
+	result := make([]float64, len(data))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var window []float64
+			for ky := -f.HalfHeight; ky <= f.HalfHeight; ky++ {
+				ny, ok := edge(y+ky, height, b, false)
+				if !ok {
+					continue
+				}
+				half := f.HalfWidths[ny]
+				for kx := -half; kx <= half; kx++ {
+					if v := neighbor(data, width, height, x+kx, ny, b); !math.IsNaN(v) {
+						window = append(window, v)
+					}
+				}
+			}
+			if len(window) == 0 {
+				result[y*width+x] = math.NaN()
+				continue
+			}
+			result[y*width+x] = reduce(window)
+		}
+	}
+	return result
+}
+
+func middle(window []float64) float64 {
+	sort.Float64s(window)
+	return window[len(window)/2]
+}
+
+func TestFootprintsMatchDirectScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(9, 10))
+	width, height := 23, 17
+	quantized := make([]float64, width*height)
+	continuous := make([]float64, width*height)
+	for i := range quantized {
+		quantized[i] = float64(r.IntN(8))
+		continuous[i] = r.NormFloat64()
+		if r.IntN(10) == 0 {
+			quantized[i], continuous[i] = math.NaN(), math.NaN()
+		}
+	}
+	f := transformers.Footprint{HalfWidths: make([]int, height), HalfHeight: 2}
+	for y := range f.HalfWidths {
+		f.HalfWidths[y] = y / 3
+	}
+	for _, b := range boundaries {
+		t.Run(fmt.Sprint(b), func(t *testing.T) {
+			opts := transformers.Options{Boundary: b}
+			ops := transformers.NewMorphologicalOps(width, height, opts)
+			eroded, err := ops.ErodeFootprint(context.Background(), continuous, f)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			assertIdentical(t, eroded, directFootprint(continuous, width, height, f, b, slices.Min))
+			dilated, err := ops.DilateFootprint(context.Background(), continuous, f)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			assertIdentical(t, dilated, directFootprint(continuous, width, height, f, b, slices.Max))
+			for _, data := range [][]float64{quantized, continuous} {
+				median, err := transformers.MedianFootprint(context.Background(), data, width, height, f, opts)
+				if err != nil {
+					t.Fatalf(`expected no error, got %v`, err)
+				}
+				assertIdentical(t, median, directFootprint(data, width, height, f, b, middle))
+			}
+		})
+	}
+}
+
+func TestGaussianRowsMatchesFastGaussian(t *testing.T) {
+	width, height := 31, 19
+	data := reflectivityGrid(width, height)
+	sigmaX := make([]float64, height)
+	for y := range sigmaX {
+		sigmaX[y] = 1.5
+	}
+	expected, err := transformers.FastGaussian(context.Background(), data, width, height, 11, 1.5)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	actual, err := transformers.GaussianRows(context.Background(), data, width, height, sigmaX, 1.5)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	assertIdentical(t, actual, expected)
+}
+
+func TestFootprintRejectsMismatchedRows(t *testing.T) {
+	data, width, height := gapGrid()
+	f := transformers.Footprint{HalfWidths: make([]int, height-1), HalfHeight: 1}
+	if _, err := transformers.MedianFootprint(context.Background(), data, width, height, f); err == nil {
+		t.Fatalf(`expected an error, got nil`)
+	}
+	ops := transformers.NewMorphologicalOps(width, height)
+	if _, err := ops.OpenCloseFootprint(context.Background(), data, f); err == nil {
+		t.Fatalf(`expected an error, got nil`)
+	}
+}
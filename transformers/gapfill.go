@@ -0,0 +1,237 @@
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// FillMethod is how FillGaps estimates the values of a gap.
+type FillMethod int
+
+const (
+	// FillInverseDistance averages the valid pixels around the gap, weighted
+	// by inverse distance. It is quick and keeps to the range of the
+	// surrounding values.
+	FillInverseDistance FillMethod = iota
+	// FillDiffusion solves Laplace's equation over the gap with the
+	// surrounding pixels held fixed, giving the smoothest surface that meets
+	// them, with no creases where gaps join valid data.
+	FillDiffusion
+)
+
+func (m FillMethod) String() string {
+	switch m {
+	case FillInverseDistance:
+		return "inverse-distance"
+	case FillDiffusion:
+		return "diffusion"
+	}
+	return fmt.Sprintf("FillMethod(%d)", int(m))
+}
+
+// GapFill says which gaps FillGaps fills and how.
+type GapFill struct {
+	// Method is how gaps are filled.
+	Method FillMethod
+	// MaxArea is the largest area of a gap that is filled. Larger gaps, such
+	// as the outside of a radar's range, are left NaN.
+	MaxArea float64
+	// RowAreas holds the area of a pixel in each row, in the unit of MaxArea.
+	// When nil every pixel has an area of 1, so MaxArea counts pixels.
+	RowAreas []float64
+	// Connectivity is which neighbors join into a gap; zero means Connect8.
+	Connectivity Connectivity
+	// Power is the exponent of the inverse-distance weights; zero means 2.
+	Power float64
+	// Iterations caps the number of diffusion sweeps; zero means 1000.
+	Iterations int
+	// Tolerance stops diffusion once no pixel changes by more than it in a
+	// sweep; zero means 1e-6.
+	Tolerance float64
+}
+
+// FillGaps returns a copy of data with every connected region of NaN pixels
+// no larger than g.MaxArea filled in from the valid pixels around it. Gaps
+// with no valid pixels around them stay NaN. Distances are measured in
+// cells. opts sets the boundary mode: with BoundaryWrapX gaps and their
+// surroundings continue across the left and right edges, and otherwise
+// pixels beyond the edges are ignored. It stops with ctx.Err() if ctx is
+// cancelled.
+func FillGaps(ctx context.Context, data []float64, width, height int, g GapFill, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if g.Method != FillInverseDistance && g.Method != FillDiffusion {
+		return nil, fmt.Errorf("unknown fill method %v", g.Method)
+	}
+	if !(g.MaxArea >= 0) {
+		return nil, fmt.Errorf("max area must be non-negative, got %v", g.MaxArea)
+	}
+	if g.RowAreas != nil && len(g.RowAreas) != height {
+		return nil, fmt.Errorf("got %d row areas for %d rows", len(g.RowAreas), height)
+	}
+	if g.Power < 0 || math.IsNaN(g.Power) || g.Tolerance < 0 || math.IsNaN(g.Tolerance) || g.Iterations < 0 {
+		return nil, fmt.Errorf("power, iterations and tolerance must be non-negative")
+	}
+	connectivity := g.Connectivity
+	if connectivity == 0 {
+		connectivity = Connect8
+	}
+	wrap := options(opts).Boundary == BoundaryWrapX
+
+	missing := make([]bool, len(data))
+	for i, v := range data {
+		missing[i] = math.IsNaN(v)
+	}
+	labels, count, err := LabelComponents(ctx, missing, width, height, connectivity, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	areas := make([]float64, count+1)
+	for i, label := range labels {
+		if g.RowAreas == nil {
+			areas[label]++
+		} else {
+			areas[label] += g.RowAreas[i/width]
+		}
+	}
+	// gaps holds the pixels of each gap to fill, and rims the valid pixels
+	// touching it.
+	gaps := make([][]int, count+1)
+	rims := make([][]int, count+1)
+	for i, label := range labels {
+		if label != 0 && areas[label] <= g.MaxArea {
+			gaps[label] = append(gaps[label], i)
+		}
+	}
+	var touching []int32
+	for i, label := range labels {
+		if label != 0 {
+			continue
+		}
+		touching = touching[:0]
+		x, y := i%width, i/width
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				j, ok := gapNeighbor(x+dx, y+dy, width, height, wrap)
+				if !ok || labels[j] == 0 || gaps[labels[j]] == nil || slices.Contains(touching, labels[j]) {
+					continue
+				}
+				touching = append(touching, labels[j])
+				rims[labels[j]] = append(rims[labels[j]], i)
+			}
+		}
+	}
+
+	result := make([]float64, len(data))
+	copy(result, data)
+	power := g.Power
+	if power == 0 {
+		power = 2
+	}
+	for label := range gaps {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(rims[label]) > 0 {
+			inverseDistance(data, result, width, gaps[label], rims[label], power, wrap)
+		}
+	}
+	if g.Method == FillDiffusion {
+		if err := diffuse(ctx, result, width, height, gaps, rims, g, wrap); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// inverseDistance sets every pixel of gap in result to the average of the
+// rim pixels of data weighted by their distance to the power of -power.
+func inverseDistance(data, result []float64, width int, gap, rim []int, power float64, wrap bool) {
+	for _, i := range gap {
+		x, y := i%width, i/width
+		var sum, weights float64
+		for _, j := range rim {
+			dx := math.Abs(float64(j%width - x))
+			if wrap {
+				dx = min(dx, float64(width)-dx)
+			}
+			dy := float64(j/width - y)
+			var w float64
+			if power == 2 {
+				w = 1 / (dx*dx + dy*dy)
+			} else {
+				w = math.Pow(math.Hypot(dx, dy), -power)
+			}
+			sum += w * data[j]
+			weights += w
+		}
+		result[i] = sum / weights
+	}
+}
+
+// diffuse relaxes the filled gaps of result towards the mean of their four
+// neighbors by Gauss-Seidel sweeps, starting from the inverse-distance fill.
+// Neighbors beyond the edges, or in gaps left NaN, are ignored.
+func diffuse(ctx context.Context, result []float64, width, height int, gaps, rims [][]int, g GapFill, wrap bool) error {
+	var pixels []int
+	for label, gap := range gaps {
+		if len(rims[label]) > 0 {
+			pixels = append(pixels, gap...)
+		}
+	}
+	iterations := g.Iterations
+	if iterations == 0 {
+		iterations = 1000
+	}
+	tolerance := g.Tolerance
+	if tolerance == 0 {
+		tolerance = 1e-6
+	}
+	for range iterations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		change := 0.0
+		for _, i := range pixels {
+			x, y := i%width, i/width
+			var sum float64
+			n := 0
+			for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				j, ok := gapNeighbor(x+d[0], y+d[1], width, height, wrap)
+				if !ok || math.IsNaN(result[j]) {
+					continue
+				}
+				sum += result[j]
+				n++
+			}
+			if n == 0 {
+				continue
+			}
+			v := sum / float64(n)
+			change = max(change, math.Abs(v-result[i]))
+			result[i] = v
+		}
+		if change <= tolerance {
+			break
+		}
+	}
+	return nil
+}
+
+// gapNeighbor returns the index of pixel (x, y), wrapping x around when wrap
+// is set, and false when it is off the grid.
+func gapNeighbor(x, y, width, height int, wrap bool) (int, bool) {
+	if y < 0 || y >= height {
+		return 0, false
+	}
+	if wrap {
+		x = (x%width + width) % width
+	} else if x < 0 || x >= width {
+		return 0, false
+	}
+	return y*width + x, true
+}
@@ -0,0 +1,72 @@
+package transformers_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// rampWithGaps is a plane of value x+2y with a 3x3 gap, a single missing
+// pixel and a missing 12-pixel strip along the bottom.
+func rampWithGaps() ([]float64, int, int) {
+	width, height := 12, 10
+	data := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			data[y*width+x] = float64(x + 2*y)
+		}
+	}
+	for y := 2; y < 5; y++ {
+		for x := 2; x < 5; x++ {
+			data[y*width+x] = math.NaN()
+		}
+	}
+	data[3*width+8] = math.NaN()
+	for x := 0; x < width; x++ {
+		data[9*width+x] = math.NaN()
+	}
+	return data, width, height
+}
+
+func TestFillGaps(t *testing.T) {
+	tests := []struct {
+		method    transformers.FillMethod
+		tolerance float64
+		gap       []int
+	}{
+		// Inverse distance reproduces a plane only where the rim is symmetric.
+		{method: transformers.FillInverseDistance, tolerance: 1e-9, gap: []int{3*12 + 8, 3*12 + 3}},
+		// A plane solves Laplace's equation, so diffusion recovers all of it.
+		{method: transformers.FillDiffusion, tolerance: 1e-4, gap: []int{3*12 + 8, 2*12 + 2, 2*12 + 4, 3*12 + 3, 4*12 + 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.method.String(), func(t *testing.T) {
+			data, width, height := rampWithGaps()
+			filled, err := transformers.FillGaps(context.Background(), data, width, height, transformers.GapFill{Method: test.method, MaxArea: 9})
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			for _, i := range test.gap {
+				if expected := float64(i%width + 2*(i/width)); math.Abs(filled[i]-expected) > test.tolerance {
+					t.Fatalf(`expected %v at %v, got %v`, expected, i, filled[i])
+				}
+			}
+			for x := 0; x < width; x++ {
+				if actual := filled[9*width+x]; !math.IsNaN(actual) {
+					t.Fatalf(`expected the large gap to stay NaN, got %v`, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestFillGapsLeavesEmptyGridsAlone(t *testing.T) {
+	data := []float64{math.NaN(), math.NaN(), math.NaN(), math.NaN()}
+	filled, err := transformers.FillGaps(context.Background(), data, 2, 2, transformers.GapFill{Method: transformers.FillDiffusion, MaxArea: 10})
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	assertIdentical(t, filled, data)
+}
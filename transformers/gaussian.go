@@ -1,15 +1,20 @@
 package transformers
 
 import (
+	"context"
+	"fmt"
 	"math"
 
 	"gonum.org/v1/gonum/mat"
 )
 
 // gaussianKernel1D creates a 1D Gaussian kernel
-func gaussianKernel1D(size int, sigma float64) []float64 {
-	if size%2 == 0 {
-		panic("kernel size must be odd")
+func gaussianKernel1D(size int, sigma float64) ([]float64, error) {
+	if size < 1 || size%2 == 0 {
+		return nil, fmt.Errorf("kernel size must be odd and positive, got %d", size)
+	}
+	if !(sigma > 0) {
+		return nil, fmt.Errorf("sigma must be positive, got %v", sigma)
 	}
 
 	kernel := make([]float64, size)
@@ -28,25 +33,38 @@ func gaussianKernel1D(size int, sigma float64) []float64 {
 		kernel[i] /= sum
 	}
 
-	return kernel
+	return kernel, nil
 }
 
 // separableConvolve2D performs separable 2D convolution, skipping NaN neighbors.
+// Row i is convolved with rowKernels[i], then every column with columnKernel.
 // Each output pixel is computed from the weighted sum of non-NaN neighbors only,
 // with the kernel renormalized over those neighbors. Pixels with no valid neighbors
-// remain NaN.
-func separableConvolve2D(data *mat.Dense, kernel []float64) *mat.Dense {
+// remain NaN. Neighbors beyond the edges are read as opts.Boundary says. Rows
+// are split across opts.Workers goroutines, and it stops with ctx.Err() if
+// ctx is cancelled.
+func separableConvolve2D(ctx context.Context, data *mat.Dense, rowKernels [][]float64, columnKernel []float64, opts Options) (*mat.Dense, error) {
 	rows, cols := data.Dims()
-	kSize := len(kernel)
-	kHalf := kSize / 2
+	pad := 0
+	for _, kernel := range rowKernels {
+		pad = max(pad, len(kernel)/2)
+	}
+	srcCols := opts.Boundary.columns(cols, pad)
+	srcRows := opts.Boundary.rows(rows, len(columnKernel)/2)
 
 	// First pass: convolve rows
 	temp := mat.NewDense(rows, cols, nil)
-	for i := 0; i < rows; i++ {
+	err := parallelRows(ctx, rows, opts, func(i int) {
+		kernel := rowKernels[i]
+		kSize := len(kernel)
+		kStart := pad - kSize/2
 		for j := 0; j < cols; j++ {
 			sum, weight := 0.0, 0.0
 			for k := 0; k < kSize; k++ {
-				srcJ := clamp(j+k-kHalf, 0, cols-1)
+				srcJ := srcCols[kStart+j+k]
+				if srcJ < 0 {
+					continue
+				}
 				v := data.At(i, srcJ)
 				if !math.IsNaN(v) {
 					sum += kernel[k] * v
@@ -59,15 +77,22 @@ func separableConvolve2D(data *mat.Dense, kernel []float64) *mat.Dense {
 				temp.Set(i, j, sum/weight)
 			}
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Second pass: convolve columns
 	result := mat.NewDense(rows, cols, nil)
-	for i := 0; i < rows; i++ {
+	kernel, kSize := columnKernel, len(columnKernel)
+	err = parallelRows(ctx, rows, opts, func(i int) {
 		for j := 0; j < cols; j++ {
 			sum, weight := 0.0, 0.0
 			for k := 0; k < kSize; k++ {
-				srcI := clamp(i+k-kHalf, 0, rows-1)
+				srcI := srcRows[i+k]
+				if srcI < 0 {
+					continue
+				}
 				v := temp.At(srcI, j)
 				if !math.IsNaN(v) {
 					sum += kernel[k] * v
@@ -80,9 +105,12 @@ func separableConvolve2D(data *mat.Dense, kernel []float64) *mat.Dense {
 				result.Set(i, j, sum/weight)
 			}
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result
+	return result, nil
 }
 
 // FastGaussian applies Gaussian smoothing to raw gridded data
@@ -91,19 +119,85 @@ func separableConvolve2D(data *mat.Dense, kernel []float64) *mat.Dense {
 // height: number of rows in the grid
 // kernelSize: size of the Gaussian kernel (must be odd, e.g., 3, 5, 7)
 // sigma: standard deviation of the Gaussian (e.g., 1.0)
-// Returns: smoothed data as a flat slice in the same format as input
-func FastGaussian(data []float64, width, height, kernelSize int, sigma float64) []float64 {
-	if len(data) != width*height {
-		panic("data length must equal width * height")
+// opts: optional worker count, NaN handling and boundary mode (see Options)
+// Returns: smoothed data as a flat slice in the same format as input, or an
+// error if the dimensions or kernel are invalid or ctx is cancelled
+func FastGaussian(ctx context.Context, data []float64, width, height, kernelSize int, sigma float64, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	kernel, err := gaussianKernel1D(kernelSize, sigma)
+	if err != nil {
+		return nil, err
+	}
+	if err := options(opts).Boundary.check(); err != nil {
+		return nil, err
 	}
 
 	// Create gonum matrix from raw data
 	matrix := mat.NewDense(height, width, data)
 
 	// Apply separable Gaussian convolution
-	kernel := gaussianKernel1D(kernelSize, sigma)
-	smoothed := separableConvolve2D(matrix, kernel)
+	rowKernels := make([][]float64, height)
+	for i := range rowKernels {
+		rowKernels[i] = kernel
+	}
+	smoothed, err := separableConvolve2D(ctx, matrix, rowKernels, kernel, options(opts))
+	if err != nil {
+		return nil, err
+	}
 
 	// Extract and return raw data
-	return smoothed.RawMatrix().Data
+	result := smoothed.RawMatrix().Data
+	preserveNaN(data, result, options(opts))
+	return result, nil
+}
+
+// GaussianRows applies Gaussian smoothing whose standard deviation, in
+// columns, is sigmaX[y] along row y, and sigmaY in rows down the columns. It
+// suits grids whose columns are closer together on the ground at some rows
+// than others (see Footprint). Each kernel is cut off at 3 standard
+// deviations; otherwise it works like FastGaussian.
+func GaussianRows(ctx context.Context, data []float64, width, height int, sigmaX []float64, sigmaY float64, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if len(sigmaX) != height {
+		return nil, fmt.Errorf("got %d row sigmas for %d rows", len(sigmaX), height)
+	}
+	if err := options(opts).Boundary.check(); err != nil {
+		return nil, err
+	}
+	columnKernel, err := truncatedGaussian(sigmaY)
+	if err != nil {
+		return nil, err
+	}
+	// Rows at similar latitudes often share a sigma, so share their kernels.
+	kernels := make(map[float64][]float64)
+	rowKernels := make([][]float64, height)
+	for i, sigma := range sigmaX {
+		if kernels[sigma] == nil {
+			if kernels[sigma], err = truncatedGaussian(sigma); err != nil {
+				return nil, err
+			}
+		}
+		rowKernels[i] = kernels[sigma]
+	}
+
+	smoothed, err := separableConvolve2D(ctx, mat.NewDense(height, width, data), rowKernels, columnKernel, options(opts))
+	if err != nil {
+		return nil, err
+	}
+	result := smoothed.RawMatrix().Data
+	preserveNaN(data, result, options(opts))
+	return result, nil
+}
+
+// truncatedGaussian returns a Gaussian kernel reaching 3 standard deviations
+// either side.
+func truncatedGaussian(sigma float64) ([]float64, error) {
+	if !(sigma > 0) || math.IsInf(sigma, 1) {
+		return nil, fmt.Errorf("sigma must be positive and finite, got %v", sigma)
+	}
+	return gaussianKernel1D(2*int(math.Ceil(3*sigma))+1, sigma)
 }
@@ -0,0 +1,65 @@
+package transformers
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hysteresis says which pixels HysteresisThreshold keeps.
+type Hysteresis struct {
+	// Weak picks the pixels that can make up a region, e.g. GreaterThan(5).
+	Weak ThresholdFunc
+	// Strong picks the pixels that make a region worth keeping, e.g.
+	// GreaterThan(30). Strong pixels count as weak ones too.
+	Strong ThresholdFunc
+	// Connectivity is which neighbors join into a region; zero means Connect8.
+	Connectivity Connectivity
+	// Replacement is what weak pixels in regions without a strong pixel are
+	// set to, e.g. NaN or a floor value.
+	Replacement float64
+}
+
+// HysteresisThreshold returns a copy of data keeping weak pixels only where
+// they are connected to a strong one, which removes noise without chopping
+// off the weaker outskirts of real features the way a single strong
+// threshold would. Pixels that aren't weak are left alone. opts sets the
+// boundary mode (see LabelComponents).
+func HysteresisThreshold(ctx context.Context, data []float64, width, height int, h Hysteresis, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if h.Weak == nil || h.Strong == nil {
+		return nil, fmt.Errorf("hysteresis weak and strong predicates are required")
+	}
+	connectivity := h.Connectivity
+	if connectivity == 0 {
+		connectivity = Connect8
+	}
+
+	member := make([]bool, len(data))
+	strong := make([]bool, len(data))
+	for i, v := range data {
+		strong[i] = h.Strong(v)
+		member[i] = strong[i] || h.Weak(v)
+	}
+	labels, count, err := LabelComponents(ctx, member, width, height, connectivity, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make([]bool, count+1)
+	for i, label := range labels {
+		if strong[i] {
+			keep[label] = true
+		}
+	}
+	result := make([]float64, len(data))
+	for i, label := range labels {
+		if label != 0 && !keep[label] {
+			result[i] = h.Replacement
+		} else {
+			result[i] = data[i]
+		}
+	}
+	return result, nil
+}
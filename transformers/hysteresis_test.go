@@ -0,0 +1,46 @@
+package transformers_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+func TestHysteresisThreshold(t *testing.T) {
+	data := []float64{
+		10, 12, 0, 0, 8, 0,
+		12, 40, 0, 0, 9, 0,
+		0, 11, 0, 0, 0, 0,
+		0, 0, 10, 0, 0, 45,
+	}
+	h := transformers.Hysteresis{
+		Weak:         transformers.GreaterThan(5),
+		Strong:       transformers.GreaterThan(30),
+		Connectivity: transformers.Connect4,
+		Replacement:  math.NaN(),
+	}
+	actual, err := transformers.HysteresisThreshold(context.Background(), data, 6, 4, h)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	nan := math.NaN()
+	expected := []float64{
+		10, 12, 0, 0, nan, 0,
+		12, 40, 0, 0, nan, 0,
+		0, 11, 0, 0, 0, 0,
+		0, 0, nan, 0, 0, 45,
+	}
+	assertIdentical(t, actual, expected)
+
+	// Corners join the lone weak pixel to the storm with 8-connectivity.
+	h.Connectivity = transformers.Connect8
+	actual, err = transformers.HysteresisThreshold(context.Background(), data, 6, 4, h)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if actual[20] != 10 {
+		t.Fatalf(`expected 10, got %v`, actual[20])
+	}
+}
@@ -1,15 +1,22 @@
 package transformers
 
 import (
+	"context"
 	"fmt"
-	"sort"
 )
 
 // MedianFilter applies a median filter to a 1D slice representing a 2D grid.
 // data: the input grid values (row-major order)
 // width, height: dimensions of the grid
 // kernelSize: size of the filter kernel (must be odd, e.g. 3, 5, 7)
-func MedianFilter(data []float64, width, height, kernelSize int) ([]float64, error) {
+// opts: optional worker count, NaN handling and boundary mode (see Options)
+// NaN neighbors are ignored, so the median is taken over the valid values in
+// the window; a window with none gives NaN. Sorting networks (3x3 and 5x5)
+// or a sliding histogram (larger kernels over data with few distinct values,
+// like 0.5 dBZ reflectivity) are used where they apply, with the same result
+// as sorting every window.
+// It stops with ctx.Err() if ctx is cancelled.
+func MedianFilter(ctx context.Context, data []float64, width, height, kernelSize int, opts ...Options) ([]float64, error) {
 	if len(data) != width*height {
 		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
 	}
@@ -20,27 +27,34 @@ func MedianFilter(data []float64, width, height, kernelSize int) ([]float64, err
 		return nil, fmt.Errorf("kernelSize must be at least 1, got %d", kernelSize)
 	}
 
-	output := make([]float64, len(data))
-	radius := kernelSize / 2
-	window := make([]float64, 0, kernelSize*kernelSize)
+	return median(ctx, data, width, height, squareFootprint(height, kernelSize), options(opts))
+}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			window = window[:0]
+// MedianFootprint applies a median filter over windows of footprint f, which
+// can vary in width from row to row (see Footprint). Otherwise it works like
+// MedianFilter, and gives the same result for the footprint of a square
+// kernel.
+func MedianFootprint(ctx context.Context, data []float64, width, height int, f Footprint, opts ...Options) ([]float64, error) {
+	if len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if err := f.check(height); err != nil {
+		return nil, err
+	}
+	return median(ctx, data, width, height, f, options(opts))
+}
 
-			// Collect neighbors within the kernel bounds, clamping at edges
-			for ky := -radius; ky <= radius; ky++ {
-				ny := clamp(y+ky, 0, height-1)
-				for kx := -radius; kx <= radius; kx++ {
-					nx := clamp(x+kx, 0, width-1)
-					window = append(window, data[ny*width+nx])
-				}
-			}
+func median(ctx context.Context, data []float64, width, height int, f Footprint, o Options) ([]float64, error) {
+	if err := o.Boundary.check(); err != nil {
+		return nil, err
+	}
 
-			sort.Float64s(window)
-			output[y*width+x] = window[len(window)/2]
-		}
+	output := make([]float64, len(data))
+	err := parallelRows(ctx, height, o, medianRow(data, output, width, height, f, o.Boundary))
+	if err != nil {
+		return nil, err
 	}
 
+	preserveNaN(data, output, o)
 	return output, nil
 }
@@ -0,0 +1,99 @@
+package transformers_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// directMedian sorts the valid values of every window, giving NaN when a
+// window has none.
+func directMedian(data []float64, width, height, kernelSize int, b transformers.Boundary) []float64 {
+	result := make([]float64, len(data))
+	half := kernelSize / 2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var window []float64
+			for ky := -half; ky <= half; ky++ {
+				for kx := -half; kx <= half; kx++ {
+					if v := neighbor(data, width, height, x+kx, y+ky, b); !math.IsNaN(v) {
+						window = append(window, v)
+					}
+				}
+			}
+			if len(window) == 0 {
+				result[y*width+x] = math.NaN()
+				continue
+			}
+			sort.Float64s(window)
+			result[y*width+x] = window[len(window)/2]
+		}
+	}
+	return result
+}
+
+func TestMedianMatchesDirectSort(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	grids := map[string]func() float64{
+		`quantized`: func() float64 {
+			if r.IntN(10) == 0 {
+				return math.NaN()
+			}
+			return float64(r.IntN(20)) * 0.5
+		},
+		`continuous`: func() float64 {
+			switch r.IntN(20) {
+			case 0:
+				return math.NaN()
+			case 1:
+				return math.Inf(1 - 2*r.IntN(2))
+			}
+			return r.NormFloat64() * 10
+		},
+		`signed zeros`: func() float64 {
+			return []float64{math.Copysign(0, -1), 0, 1, -1, math.NaN()}[r.IntN(5)]
+		},
+		`mostly missing`: func() float64 {
+			if r.IntN(4) != 0 {
+				return math.NaN()
+			}
+			return float64(r.IntN(5))
+		},
+	}
+	for name, value := range grids {
+		for _, size := range [][2]int{{1, 1}, {4, 3}, {2, 11}, {37, 29}} {
+			width, height := size[0], size[1]
+			data := make([]float64, width*height)
+			for i := range data {
+				data[i] = value()
+			}
+			for _, kernel := range []int{1, 3, 5, 7, 9} {
+				t.Run(fmt.Sprintf(`%v/%dx%d/kernel=%d`, name, width, height, kernel), func(t *testing.T) {
+					actual, err := transformers.MedianFilter(context.Background(), data, width, height, kernel)
+					if err != nil {
+						t.Fatalf(`expected no error, got %v`, err)
+					}
+					assertIdentical(t, actual, directMedian(data, width, height, kernel, transformers.BoundaryClamp))
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkMedian(b *testing.B) {
+	data := reflectivityGrid(mrmsWidth, mrmsHeight)
+	for _, kernel := range []int{3, 5, 7, 11} {
+		b.Run(fmt.Sprintf(`kernel=%d`, kernel), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := transformers.MedianFilter(context.Background(), data, mrmsWidth, mrmsHeight, kernel); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
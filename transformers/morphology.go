@@ -1,175 +1,204 @@
 package transformers
 
 import (
-	"math"
+	"context"
+	"fmt"
 )
 
 // MorphologicalOps provides morphological image processing operations
 type MorphologicalOps struct {
 	width  int
 	height int
+	opts   Options
 }
 
-// NewMorphologicalOps creates a new morphological operations processor
-func NewMorphologicalOps(width, height int) *MorphologicalOps {
+// NewMorphologicalOps creates a new morphological operations processor.
+// opts optionally sets the worker count, NaN handling and boundary mode (see
+// Options).
+func NewMorphologicalOps(width, height int, opts ...Options) *MorphologicalOps {
 	return &MorphologicalOps{
 		width:  width,
 		height: height,
+		opts:   options(opts),
 	}
 }
 
-// Erode performs morphological erosion with a given kernel size
-// Replaces each pixel with the minimum in its neighborhood
-func (m *MorphologicalOps) Erode(data []float64, kernelSize int) []float64 {
-	result := make([]float64, len(data))
-	halfKernel := kernelSize / 2
-
-	for y := 0; y < m.height; y++ {
-		for x := 0; x < m.width; x++ {
-			minVal := math.Inf(1)
-
-			// Scan kernel neighborhood
-			for ky := -halfKernel; ky <= halfKernel; ky++ {
-				for kx := -halfKernel; kx <= halfKernel; kx++ {
-					nx := x + kx
-					ny := y + ky
-
-					// Boundary handling: clamp to edges
-					if nx < 0 {
-						nx = 0
-					}
-					if nx >= m.width {
-						nx = m.width - 1
-					}
-					if ny < 0 {
-						ny = 0
-					}
-					if ny >= m.height {
-						ny = m.height - 1
-					}
-
-					idx := ny*m.width + nx
-					if data[idx] < minVal {
-						minVal = data[idx]
-					}
-				}
-			}
-
-			result[y*m.width+x] = minVal
-		}
+func (m *MorphologicalOps) check(data []float64) error {
+	if m.width <= 0 || m.height <= 0 || len(data) != m.width*m.height {
+		return fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), m.width, m.height, m.width*m.height)
 	}
+	return m.opts.Boundary.check()
+}
 
-	return result
+// Erode performs morphological erosion with a given kernel size
+// Replaces each pixel with the minimum in its neighborhood, reading beyond
+// the edges as the Boundary option says. NaN neighbors are ignored; a
+// neighborhood of only NaN gives NaN.
+// Runs in constant time per pixel whatever the kernel size (see extremeFilter).
+func (m *MorphologicalOps) Erode(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	if err := checkKernelSize(kernelSize); err != nil {
+		return nil, err
+	}
+	return m.extreme(ctx, data, squareFootprint(m.height, kernelSize), true)
 }
 
 // Dilate performs morphological dilation with a given kernel size
-// Replaces each pixel with the maximum in its neighborhood
-func (m *MorphologicalOps) Dilate(data []float64, kernelSize int) []float64 {
-	result := make([]float64, len(data))
-	halfKernel := kernelSize / 2
-
-	for y := 0; y < m.height; y++ {
-		for x := 0; x < m.width; x++ {
-			maxVal := math.Inf(-1)
-
-			// Scan kernel neighborhood
-			for ky := -halfKernel; ky <= halfKernel; ky++ {
-				for kx := -halfKernel; kx <= halfKernel; kx++ {
-					nx := x + kx
-					ny := y + ky
-
-					// Boundary handling: clamp to edges
-					if nx < 0 {
-						nx = 0
-					}
-					if nx >= m.width {
-						nx = m.width - 1
-					}
-					if ny < 0 {
-						ny = 0
-					}
-					if ny >= m.height {
-						ny = m.height - 1
-					}
-
-					idx := ny*m.width + nx
-					if data[idx] > maxVal {
-						maxVal = data[idx]
-					}
-				}
-			}
-
-			result[y*m.width+x] = maxVal
-		}
+// Replaces each pixel with the maximum in its neighborhood, reading beyond
+// the edges as the Boundary option says. NaN neighbors are ignored; a
+// neighborhood of only NaN gives NaN.
+func (m *MorphologicalOps) Dilate(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	if err := checkKernelSize(kernelSize); err != nil {
+		return nil, err
+	}
+	return m.extreme(ctx, data, squareFootprint(m.height, kernelSize), false)
+}
+
+func checkKernelSize(kernelSize int) error {
+	if kernelSize < 1 {
+		return fmt.Errorf("kernelSize must be at least 1, got %d", kernelSize)
+	}
+	return nil
+}
+
+// ErodeFootprint is Erode over windows of footprint f, which can vary in
+// width from row to row (see Footprint).
+func (m *MorphologicalOps) ErodeFootprint(ctx context.Context, data []float64, f Footprint) ([]float64, error) {
+	if err := f.check(m.height); err != nil {
+		return nil, err
 	}
+	return m.extreme(ctx, data, f, true)
+}
+
+// DilateFootprint is Dilate over windows of footprint f, which can vary in
+// width from row to row (see Footprint).
+func (m *MorphologicalOps) DilateFootprint(ctx context.Context, data []float64, f Footprint) ([]float64, error) {
+	if err := f.check(m.height); err != nil {
+		return nil, err
+	}
+	return m.extreme(ctx, data, f, false)
+}
 
-	return result
+func (m *MorphologicalOps) extreme(ctx context.Context, data []float64, f Footprint, isMin bool) ([]float64, error) {
+	if err := m.check(data); err != nil {
+		return nil, err
+	}
+	return extremeFilter(ctx, data, m.width, m.height, f, isMin, m.opts)
 }
 
 // Open performs morphological opening (erosion followed by dilation)
 // Removes small bright features while preserving larger structures
-func (m *MorphologicalOps) Open(data []float64, kernelSize int) []float64 {
-	eroded := m.Erode(data, kernelSize)
-	return m.Dilate(eroded, kernelSize)
+func (m *MorphologicalOps) Open(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	eroded, err := m.Erode(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
+	return m.Dilate(ctx, eroded, kernelSize)
 }
 
 // Close performs morphological closing (dilation followed by erosion)
 // Fills small holes and connects nearby features
-func (m *MorphologicalOps) Close(data []float64, kernelSize int) []float64 {
-	dilated := m.Dilate(data, kernelSize)
-	return m.Erode(dilated, kernelSize)
+func (m *MorphologicalOps) Close(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	dilated, err := m.Dilate(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
+	return m.Erode(ctx, dilated, kernelSize)
 }
 
 // Gradient computes morphological gradient (dilation - erosion)
 // Highlights edges and boundaries
-func (m *MorphologicalOps) Gradient(data []float64, kernelSize int) []float64 {
-	dilated := m.Dilate(data, kernelSize)
-	eroded := m.Erode(data, kernelSize)
+func (m *MorphologicalOps) Gradient(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	dilated, err := m.Dilate(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
+	eroded, err := m.Erode(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
 
 	result := make([]float64, len(data))
 	for i := range result {
 		result[i] = dilated[i] - eroded[i]
 	}
 
-	return result
+	return result, nil
 }
 
 // TopHat computes white top-hat (original - opening)
 // Extracts small bright features
-func (m *MorphologicalOps) TopHat(data []float64, kernelSize int) []float64 {
-	opened := m.Open(data, kernelSize)
+func (m *MorphologicalOps) TopHat(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	opened, err := m.Open(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
 
 	result := make([]float64, len(data))
 	for i := range result {
 		result[i] = data[i] - opened[i]
 	}
 
-	return result
+	return result, nil
 }
 
 // BlackHat computes black top-hat (closing - original)
 // Extracts small dark features
-func (m *MorphologicalOps) BlackHat(data []float64, kernelSize int) []float64 {
-	closed := m.Close(data, kernelSize)
+func (m *MorphologicalOps) BlackHat(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	closed, err := m.Close(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
 
 	result := make([]float64, len(data))
 	for i := range result {
 		result[i] = closed[i] - data[i]
 	}
 
-	return result
+	return result, nil
 }
 
 // OpenClose performs opening followed by closing
 // Good general noise reduction for weather data
-func (m *MorphologicalOps) OpenClose(data []float64, kernelSize int) []float64 {
-	opened := m.Open(data, kernelSize)
-	return m.Close(opened, kernelSize)
+func (m *MorphologicalOps) OpenClose(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	opened, err := m.Open(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
+	return m.Close(ctx, opened, kernelSize)
 }
 
 // CloseOpen performs closing followed by opening
 // Alternative noise reduction approach
-func (m *MorphologicalOps) CloseOpen(data []float64, kernelSize int) []float64 {
-	closed := m.Close(data, kernelSize)
-	return m.Open(closed, kernelSize)
+func (m *MorphologicalOps) CloseOpen(ctx context.Context, data []float64, kernelSize int) ([]float64, error) {
+	closed, err := m.Close(ctx, data, kernelSize)
+	if err != nil {
+		return nil, err
+	}
+	return m.Open(ctx, closed, kernelSize)
+}
+
+// OpenCloseFootprint is OpenClose over windows of footprint f.
+func (m *MorphologicalOps) OpenCloseFootprint(ctx context.Context, data []float64, f Footprint) ([]float64, error) {
+	return m.footprintSequence(ctx, data, f, true, false, false, true)
+}
+
+// CloseOpenFootprint is CloseOpen over windows of footprint f.
+func (m *MorphologicalOps) CloseOpenFootprint(ctx context.Context, data []float64, f Footprint) ([]float64, error) {
+	return m.footprintSequence(ctx, data, f, false, true, true, false)
+}
+
+// footprintSequence erodes (true) or dilates (false) data over f in turn for
+// each of steps.
+func (m *MorphologicalOps) footprintSequence(ctx context.Context, data []float64, f Footprint, steps ...bool) ([]float64, error) {
+	if err := f.check(m.height); err != nil {
+		return nil, err
+	}
+	var err error
+	for _, erode := range steps {
+		data, err = m.extreme(ctx, data, f, erode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
 }
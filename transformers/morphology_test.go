@@ -0,0 +1,118 @@
+package transformers_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// directExtreme scans every window, ignoring NaN and giving NaN when a window
+// has no valid pixels.
+func directExtreme(data []float64, width, height, kernelSize int, isMin bool, b transformers.Boundary) []float64 {
+	result := make([]float64, len(data))
+	half := kernelSize / 2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			best, found := math.Inf(-1), false
+			if isMin {
+				best = math.Inf(1)
+			}
+			for ky := -half; ky <= half; ky++ {
+				for kx := -half; kx <= half; kx++ {
+					v := neighbor(data, width, height, x+kx, y+ky, b)
+					if math.IsNaN(v) {
+						continue
+					}
+					found = true
+					if (isMin && v < best) || (!isMin && v > best) {
+						best = v
+					}
+				}
+			}
+			if !found {
+				best = math.NaN()
+			}
+			result[y*width+x] = best
+		}
+	}
+	return result
+}
+
+func TestErodeDilateMatchDirectScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, size := range [][2]int{{1, 1}, {5, 3}, {2, 9}, {40, 23}} {
+		width, height := size[0], size[1]
+		data := make([]float64, width*height)
+		for i := range data {
+			switch r.IntN(12) {
+			case 0:
+				data[i] = math.NaN()
+			case 1:
+				data[i] = math.Inf(1 - 2*r.IntN(2))
+			default:
+				data[i] = r.NormFloat64() * 10
+			}
+		}
+		for kernel := 1; kernel <= 9; kernel++ {
+			t.Run(fmt.Sprintf(`%dx%d/kernel=%d`, width, height, kernel), func(t *testing.T) {
+				ops := transformers.NewMorphologicalOps(width, height)
+				eroded, err := ops.Erode(context.Background(), data, kernel)
+				if err != nil {
+					t.Fatalf(`expected no error, got %v`, err)
+				}
+				dilated, err := ops.Dilate(context.Background(), data, kernel)
+				if err != nil {
+					t.Fatalf(`expected no error, got %v`, err)
+				}
+				assertIdentical(t, eroded, directExtreme(data, width, height, kernel, true, transformers.BoundaryClamp))
+				assertIdentical(t, dilated, directExtreme(data, width, height, kernel, false, transformers.BoundaryClamp))
+			})
+		}
+	}
+}
+
+func TestMorphologyRejectsEmptyKernel(t *testing.T) {
+	ops := transformers.NewMorphologicalOps(3, 2)
+	data := []float64{0, 1, 2, 3, 4, 5}
+	for _, kernel := range []int{0, -1, -3} {
+		if _, err := ops.Erode(context.Background(), data, kernel); err == nil {
+			t.Fatalf(`expected an error eroding with kernel %v, got nil`, kernel)
+		}
+		if _, err := ops.TopHat(context.Background(), data, kernel); err == nil {
+			t.Fatalf(`expected an error for a top-hat with kernel %v, got nil`, kernel)
+		}
+	}
+}
+
+func BenchmarkErode(b *testing.B) {
+	data := reflectivityGrid(mrmsWidth, mrmsHeight)
+	ops := transformers.NewMorphologicalOps(mrmsWidth, mrmsHeight)
+	for _, kernel := range []int{3, 9, 21} {
+		b.Run(fmt.Sprintf(`kernel=%d`, kernel), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := ops.Erode(context.Background(), data, kernel); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func assertIdentical(t *testing.T, actual, expected []float64) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf(`expected %v values, got %v`, len(expected), len(actual))
+	}
+	for i := range expected {
+		if math.IsNaN(actual[i]) && math.IsNaN(expected[i]) {
+			continue
+		}
+		if math.Float64bits(actual[i]) != math.Float64bits(expected[i]) {
+			t.Fatalf(`expected %v at %d, got %v`, expected[i], i, actual[i])
+		}
+	}
+}
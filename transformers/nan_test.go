@@ -0,0 +1,84 @@
+package transformers_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// gapGrid returns a 12x12 grid of 10s with a single NaN pixel at (9, 9) and
+// a NaN block covering the 6x6 corner at the origin.
+func gapGrid() (data []float64, width, height int) {
+	width, height = 12, 12
+	data = make([]float64, width*height)
+	for i := range data {
+		data[i] = 10
+	}
+	data[9*width+9] = math.NaN()
+	for y := range 6 {
+		for x := range 6 {
+			data[y*width+x] = math.NaN()
+		}
+	}
+	return data, width, height
+}
+
+func TestFiltersTreatNaNAsMissing(t *testing.T) {
+	data, width, height := gapGrid()
+	for name, apply := range filters {
+		t.Run(name, func(t *testing.T) {
+			result, err := apply(context.Background(), data, width, height, transformers.Options{})
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			if actual := result[9*width+9]; math.Abs(actual-10) > 1e-9 {
+				t.Fatalf(`expected the gap to be filled with 10, got %v`, actual)
+			}
+			if actual := result[0]; !math.IsNaN(actual) {
+				t.Fatalf(`expected NaN inside the NaN block, got %v`, actual)
+			}
+			if actual := result[width-1]; math.Abs(actual-10) > 1e-9 {
+				t.Fatalf(`expected 10 away from the gaps, got %v`, actual)
+			}
+		})
+	}
+}
+
+func TestFiltersPreserveNaN(t *testing.T) {
+	data, width, height := gapGrid()
+	for name, apply := range filters {
+		t.Run(name, func(t *testing.T) {
+			result, err := apply(context.Background(), data, width, height, transformers.Options{PreserveNaN: true})
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			for i, v := range data {
+				if math.IsNaN(v) != math.IsNaN(result[i]) {
+					t.Fatalf(`expected NaN mask unchanged at %d, got %v from %v`, i, result[i], v)
+				}
+				if !math.IsNaN(v) && math.Abs(result[i]-10) > 1e-9 {
+					t.Fatalf(`expected 10 at %d, got %v`, i, result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMedianIgnoresNaN(t *testing.T) {
+	nan := math.NaN()
+	data := []float64{
+		nan, 1, nan,
+		5, nan, 2,
+		nan, 9, nan,
+	}
+	result, err := transformers.MedianFilter(context.Background(), data, 3, 3, 3)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	// The window of the center pixel holds 1, 2, 5 and 9.
+	if actual := result[4]; actual != 5 {
+		t.Fatalf(`expected 5, got %v`, actual)
+	}
+}
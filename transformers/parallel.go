@@ -0,0 +1,91 @@
+package transformers
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Options configures how the filters in this package run.
+type Options struct {
+	// Workers is the number of goroutines a filter splits its rows across.
+	// Zero or less uses runtime.GOMAXPROCS(0). Output doesn't depend on it.
+	Workers int
+	// PreserveNaN keeps pixels that are NaN in the input NaN in the output.
+	// Every filter treats NaN as missing and ignores it in a neighborhood, so
+	// by default gaps next to valid data are filled in; with PreserveNaN the
+	// filters only smooth existing values and the NaN mask is unchanged.
+	PreserveNaN bool
+	// Boundary says what filters read for neighbors beyond the edges of the
+	// grid. The zero value, BoundaryDefault, keeps each filter's own rule.
+	Boundary Boundary
+}
+
+// options returns the first of opts, or the zero Options.
+func options(opts []Options) Options {
+	if len(opts) == 0 {
+		return Options{}
+	}
+	return opts[0]
+}
+
+func (o Options) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// preserveNaN copies input's NaN mask onto output when opts asks for it.
+func preserveNaN(input, output []float64, opts Options) {
+	if !opts.PreserveNaN {
+		return
+	}
+	for i, v := range input {
+		if math.IsNaN(v) {
+			output[i] = v
+		}
+	}
+}
+
+// rowBand is how many rows a worker takes at a time, and so how often it
+// checks its context: often enough that cancelling stops even a CONUS-sized
+// pass promptly, without paying for a check on every pixel.
+const rowBand = 16
+
+// parallelRows calls row(y) once for every y in [0, height), handing out
+// bands of rowBand rows to the configured number of workers. Each output row
+// must depend only on the input, never on other output rows, so the result
+// is identical to a serial pass whatever the worker count. It stops handing
+// out bands once ctx is cancelled and returns ctx.Err().
+func parallelRows(ctx context.Context, height int, opts Options, row func(y int)) error {
+	return parallelBands(ctx, height, opts, func(start, end int) {
+		for y := start; y < end; y++ {
+			row(y)
+		}
+	})
+}
+
+// parallelBands is parallelRows for filters with working buffers, calling
+// band(start, end) once for each band of rows [start, end) so the buffers
+// can be set up once per band and reused across its rows.
+func parallelBands(ctx context.Context, height int, opts Options, band func(start, end int)) error {
+	workers := min(opts.workers(), (height+rowBand-1)/rowBand)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for ctx.Err() == nil {
+				start := int(next.Add(rowBand)) - rowBand
+				if start >= height {
+					return
+				}
+				band(start, min(start+rowBand, height))
+			}
+		})
+	}
+	wg.Wait()
+	return ctx.Err()
+}
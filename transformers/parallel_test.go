@@ -0,0 +1,85 @@
+package transformers_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+// MRMS CONUS grid dimensions.
+const (
+	mrmsWidth  = 7000
+	mrmsHeight = 3500
+)
+
+// reflectivityGrid returns a deterministic grid of 0.5 dBZ steps with
+// scattered NaN gaps, roughly like MRMS reflectivity.
+func reflectivityGrid(width, height int) []float64 {
+	r := rand.New(rand.NewPCG(1, 2))
+	data := make([]float64, width*height)
+	for i := range data {
+		if r.IntN(50) == 0 {
+			data[i] = math.NaN()
+			continue
+		}
+		data[i] = float64(r.IntN(150)) * 0.5
+	}
+	return data
+}
+
+type filter func(ctx context.Context, data []float64, width, height int, opts transformers.Options) ([]float64, error)
+
+var filters = map[string]filter{
+	`gaussian`: func(ctx context.Context, data []float64, width, height int, opts transformers.Options) ([]float64, error) {
+		return transformers.FastGaussian(ctx, data, width, height, 5, 1.5, opts)
+	},
+	`median`: func(ctx context.Context, data []float64, width, height int, opts transformers.Options) ([]float64, error) {
+		return transformers.MedianFilter(ctx, data, width, height, 3, opts)
+	},
+	`bilateral`: func(ctx context.Context, data []float64, width, height int, opts transformers.Options) ([]float64, error) {
+		return transformers.BilateralFilter(ctx, data, width, height, 1, 5, opts)
+	},
+	`open-close`: func(ctx context.Context, data []float64, width, height int, opts transformers.Options) ([]float64, error) {
+		return transformers.NewMorphologicalOps(width, height, opts).OpenClose(ctx, data, 3)
+	},
+}
+
+func TestParallelMatchesSerial(t *testing.T) {
+	width, height := 123, 77
+	data := reflectivityGrid(width, height)
+	for name, apply := range filters {
+		t.Run(name, func(t *testing.T) {
+			serial, err := apply(context.Background(), data, width, height, transformers.Options{Workers: 1})
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			for _, workers := range []int{0, 3, 16} {
+				parallel, err := apply(context.Background(), data, width, height, transformers.Options{Workers: workers})
+				if err != nil {
+					t.Fatalf(`expected no error, got %v`, err)
+				}
+				assertIdentical(t, parallel, serial)
+			}
+		})
+	}
+}
+
+func BenchmarkFilters(b *testing.B) {
+	data := reflectivityGrid(mrmsWidth, mrmsHeight)
+	for name, apply := range filters {
+		for _, workers := range []int{1, 0} {
+			b.Run(fmt.Sprintf(`%v/workers=%d`, name, workers), func(b *testing.B) {
+				for b.Loop() {
+					_, err := apply(context.Background(), data, mrmsWidth, mrmsHeight, transformers.Options{Workers: workers})
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
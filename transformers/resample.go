@@ -0,0 +1,230 @@
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Interpolation is how values between grid points are estimated.
+type Interpolation int
+
+const (
+	// InterpolateNearest takes the value of the nearest point.
+	InterpolateNearest Interpolation = iota
+	// InterpolateBilinear blends the 2x2 surrounding points linearly. It
+	// never overshoots them, but leaves creases along grid lines.
+	InterpolateBilinear
+	// InterpolateBicubic fits a Catmull-Rom cubic through the 4x4
+	// surrounding points, which is smooth but can overshoot sharp edges a
+	// little.
+	InterpolateBicubic
+	// InterpolateLanczos uses a 6x6 Lanczos window, the sharpest of these
+	// with the most ringing around sharp edges.
+	InterpolateLanczos
+)
+
+func (m Interpolation) String() string {
+	switch m {
+	case InterpolateNearest:
+		return "nearest"
+	case InterpolateBilinear:
+		return "bilinear"
+	case InterpolateBicubic:
+		return "bicubic"
+	case InterpolateLanczos:
+		return "lanczos"
+	default:
+		return fmt.Sprintf("Interpolation(%d)", int(m))
+	}
+}
+
+func (m Interpolation) check() error {
+	if m < InterpolateNearest || m > InterpolateLanczos {
+		return fmt.Errorf("unknown interpolation %v", m)
+	}
+	return nil
+}
+
+// support is the number of points along each axis a sample reads.
+func (m Interpolation) support() int {
+	switch m {
+	case InterpolateBilinear:
+		return 2
+	case InterpolateBicubic:
+		return 4
+	case InterpolateLanczos:
+		return 6
+	default:
+		return 1
+	}
+}
+
+// kernel fills weights, of length support(), with the weights of the points
+// around position s along an axis, and returns the index of the first.
+func (m Interpolation) kernel(s float64, weights []float64) int {
+	if m == InterpolateNearest {
+		weights[0] = 1
+		return int(math.Floor(s + 0.5))
+	}
+	first := int(math.Floor(s)) - m.support()/2 + 1
+	var sum float64
+	for k := range weights {
+		d := math.Abs(s - float64(first+k))
+		switch m {
+		case InterpolateBilinear:
+			weights[k] = 1 - d
+		case InterpolateBicubic:
+			weights[k] = catmullRom(d)
+		case InterpolateLanczos:
+			weights[k] = lanczos3(d)
+		}
+		sum += weights[k]
+	}
+	// Lanczos weights only sum to 1 approximately; without this a constant
+	// field would ripple.
+	for k := range weights {
+		weights[k] /= sum
+	}
+	return first
+}
+
+// catmullRom is the Keys cubic convolution kernel with a = -0.5.
+func catmullRom(d float64) float64 {
+	switch {
+	case d < 1:
+		return (1.5*d-2.5)*d*d + 1
+	case d < 2:
+		return ((-0.5*d+2.5)*d-4)*d + 2
+	default:
+		return 0
+	}
+}
+
+func lanczos3(d float64) float64 {
+	switch {
+	case d == 0:
+		return 1
+	case d < 3:
+		x := math.Pi * d
+		return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+	default:
+		return 0
+	}
+}
+
+// axisTaps holds, for each position along an axis, the support() points it
+// reads, or -1 where they fall off the grid, and their weights.
+type axisTaps struct {
+	sources []int
+	weights []float64
+}
+
+func (m Interpolation) axis(positions []float64, n int, b Boundary, periodic bool) axisTaps {
+	support := m.support()
+	taps := axisTaps{sources: make([]int, len(positions)*support), weights: make([]float64, len(positions)*support)}
+	for p, s := range positions {
+		weights := taps.weights[p*support : (p+1)*support]
+		first := m.kernel(s, weights)
+		for k := range weights {
+			taps.sources[p*support+k] = b.source(first+k, n, periodic)
+		}
+	}
+	return taps
+}
+
+// Interpolate samples data at every fractional column xs[i] and row ys[j],
+// returning a grid of len(xs) columns and len(ys) rows. Column 0.5 is halfway
+// between the first two columns. NaN is missing: a sample ignores the points
+// it reads that are NaN and renormalizes the weights of the rest, and is NaN
+// when missing points carry more than half its weight, so gaps keep their
+// shape rather than growing or shrinking. opts sets the boundary mode for
+// points read beyond the edges. It stops with ctx.Err() if ctx is cancelled.
+func Interpolate(ctx context.Context, data []float64, width, height int, xs, ys []float64, m Interpolation, opts ...Options) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	for _, positions := range [][]float64{xs, ys} {
+		for _, s := range positions {
+			if math.IsNaN(s) || math.IsInf(s, 0) {
+				return nil, fmt.Errorf("sample positions must be finite, got %v", s)
+			}
+		}
+	}
+	o := options(opts)
+	if err := o.Boundary.check(); err != nil {
+		return nil, err
+	}
+
+	support := m.support()
+	columns := m.axis(xs, width, o.Boundary, true)
+	rows := m.axis(ys, height, o.Boundary, false)
+	result := make([]float64, len(xs)*len(ys))
+	err := parallelRows(ctx, len(ys), o, func(y int) {
+		out := result[y*len(xs) : (y+1)*len(xs)]
+		for x := range out {
+			var sum, valid float64
+			for ky := 0; ky < support; ky++ {
+				sy, wy := rows.sources[y*support+ky], rows.weights[y*support+ky]
+				if sy < 0 || wy == 0 {
+					continue
+				}
+				line := data[sy*width : (sy+1)*width]
+				for kx := 0; kx < support; kx++ {
+					sx, wx := columns.sources[x*support+kx], columns.weights[x*support+kx]
+					if sx < 0 || wx == 0 || math.IsNaN(line[sx]) {
+						continue
+					}
+					sum += wx * wy * line[sx]
+					valid += wx * wy
+				}
+			}
+			if valid < 0.5 {
+				out[x] = math.NaN()
+			} else {
+				out[x] = sum / valid
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ResamplePositions returns the positions along an axis of n points at which
+// Resample samples it to scale it by factor: every 1/factor points from the
+// first, as far as the last. A factor of 4 turns 0.25° spacing into 0.0625°
+// and 11 points into 41, keeping the first and last. The last is only kept
+// when (n-1)*factor is whole; otherwise the samples stop short of it, e.g. at
+// 2.667 for 4 points and a factor of 1.5.
+func ResamplePositions(n int, factor float64) []float64 {
+	count := int(math.Floor(float64(n-1)*factor+1e-9)) + 1
+	positions := make([]float64, count)
+	for i := range positions {
+		positions[i] = float64(i) / factor
+	}
+	return positions
+}
+
+// Resample scales data by factor along both axes, interpolating with m (see
+// Interpolate), and returns the new grid and its width and height. Factors
+// below 1 sample the interpolated surface without smoothing it first, so
+// downsampling noisy fields should be preceded by a smoothing filter.
+func Resample(ctx context.Context, data []float64, width, height int, factor float64, m Interpolation, opts ...Options) ([]float64, int, int, error) {
+	if !(factor > 0) || math.IsInf(factor, 1) {
+		return nil, 0, 0, fmt.Errorf("resample factor must be positive, got %v", factor)
+	}
+	if width <= 0 || height <= 0 || len(data) != width*height {
+		return nil, 0, 0, fmt.Errorf("data length %d does not match width*height (%d*%d=%d)", len(data), width, height, width*height)
+	}
+	xs, ys := ResamplePositions(width, factor), ResamplePositions(height, factor)
+	result, err := Interpolate(ctx, data, width, height, xs, ys, m, opts...)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return result, len(xs), len(ys), nil
+}
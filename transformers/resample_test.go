@@ -0,0 +1,100 @@
+package transformers_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands/transformers"
+)
+
+var interpolations = []transformers.Interpolation{
+	transformers.InterpolateNearest,
+	transformers.InterpolateBilinear,
+	transformers.InterpolateBicubic,
+	transformers.InterpolateLanczos,
+}
+
+// reach is how many points either side of a position each interpolation
+// reads, counting the one below it.
+var reach = map[transformers.Interpolation]int{
+	transformers.InterpolateNearest:  1,
+	transformers.InterpolateBilinear: 1,
+	transformers.InterpolateBicubic:  2,
+	transformers.InterpolateLanczos:  3,
+}
+
+func inside(s float64, n, reach int) bool {
+	return s >= float64(reach-1) && s <= float64(n-reach)
+}
+
+func TestResampleKeepsSmoothFields(t *testing.T) {
+	width, height := 9, 7
+	plane := make([]float64, width*height)
+	for i := range plane {
+		plane[i] = float64(i%width) - 0.5*float64(i/width)
+	}
+	for _, m := range interpolations {
+		t.Run(m.String(), func(t *testing.T) {
+			// Lanczos weights only reproduce a plane approximately.
+			tolerance := 1e-9
+			if m == transformers.InterpolateLanczos {
+				tolerance = 0.05
+			}
+			resampled, w, h, err := transformers.Resample(context.Background(), plane, width, height, 2.5, m)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			if w != 21 || h != 16 {
+				t.Fatalf(`expected 21x16, got %vx%v`, w, h)
+			}
+			xs, ys := transformers.ResamplePositions(width, 2.5), transformers.ResamplePositions(height, 2.5)
+			for y := range h {
+				for x := range w {
+					expected := xs[x] - 0.5*ys[y]
+					if m == transformers.InterpolateNearest {
+						expected = math.Floor(xs[x]+0.5) - 0.5*math.Floor(ys[y]+0.5)
+					}
+					// Clamped edges bend the plane for the wider kernels.
+					if !inside(xs[x], width, reach[m]) || !inside(ys[y], height, reach[m]) {
+						continue
+					}
+					if actual := resampled[y*w+x]; math.Abs(actual-expected) > tolerance {
+						t.Fatalf(`expected %v at (%v, %v), got %v`, expected, xs[x], ys[y], actual)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInterpolateKeepsGapShape(t *testing.T) {
+	data := []float64{
+		1, 1, math.NaN(), math.NaN(),
+		1, 1, math.NaN(), math.NaN(),
+	}
+	xs := []float64{0, 1, 1.4, 1.6, 2, 3}
+	for _, m := range interpolations {
+		t.Run(m.String(), func(t *testing.T) {
+			actual, err := transformers.Interpolate(context.Background(), data, 4, 2, xs, []float64{0.5}, m)
+			if err != nil {
+				t.Fatalf(`expected no error, got %v`, err)
+			}
+			nan := math.NaN()
+			assertIdentical(t, actual, []float64{1, 1, 1, nan, nan, nan})
+		})
+	}
+}
+
+func TestInterpolateRejectsBadArguments(t *testing.T) {
+	data := []float64{1, 2, 3, 4}
+	if _, err := transformers.Interpolate(context.Background(), data, 2, 2, []float64{math.NaN()}, []float64{0}, transformers.InterpolateBilinear); err == nil {
+		t.Fatalf(`expected an error, got nil`)
+	}
+	if _, err := transformers.Interpolate(context.Background(), data, 2, 2, []float64{0}, []float64{0}, transformers.Interpolation(9)); err == nil {
+		t.Fatalf(`expected an error, got nil`)
+	}
+	if _, _, _, err := transformers.Resample(context.Background(), data, 2, 2, 0, transformers.InterpolateBilinear); err == nil {
+		t.Fatalf(`expected an error, got nil`)
+	}
+}
@@ -0,0 +1,223 @@
+package transformers
+
+import (
+	"context"
+	"math"
+	"slices"
+)
+
+// columnStrip is how many columns the vertical pass of extremeFilter gathers
+// at a time, so it reads whole cache lines instead of striding down one
+// column at a time.
+const columnStrip = 32
+
+// extremeFilter computes the minimum (or maximum) of every window of
+// footprint f, reading beyond the edges as opts.Boundary says. NaN pixels are
+// ignored, and windows with no valid pixel give NaN.
+func extremeFilter(ctx context.Context, data []float64, width, height int, f Footprint, isMin bool, opts Options) ([]float64, error) {
+	if f.HalfHeight < 0 {
+		// An empty window has no valid pixels.
+		result := make([]float64, len(data))
+		for i := range result {
+			result[i] = math.NaN()
+		}
+		return result, nil
+	}
+	result, err := extremePasses(ctx, data, width, height, f, isMin, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Windows of only NaN come out as the missing sentinel, which a genuine
+	// infinity could also produce, so find them with a max filter over the
+	// valid-pixel mask instead.
+	if slices.ContainsFunc(data, math.IsNaN) {
+		valid := make([]float64, len(data))
+		for i, v := range data {
+			if !math.IsNaN(v) {
+				valid[i] = 1
+			}
+		}
+		anyValid, err := extremePasses(ctx, valid, width, height, f, false, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range anyValid {
+			if v == 0 {
+				result[i] = math.NaN()
+			}
+		}
+	}
+	preserveNaN(data, result, opts)
+	return result, nil
+}
+
+// extremePasses runs extremeFilter as a horizontal pass followed by a
+// vertical one, with NaN replaced by an infinity that never wins. Each pass
+// uses the van Herk/Gil-Werman algorithm, which needs about three
+// comparisons per pixel whatever the window size. Since the horizontal pass
+// filters each row with its own half-width, each row of a window spans the
+// half-width of the grid row it reads, as Footprint describes.
+func extremePasses(ctx context.Context, data []float64, width, height int, f Footprint, isMin bool, opts Options) ([]float64, error) {
+	missing := math.Inf(-1)
+	if isMin {
+		missing = math.Inf(1)
+	}
+
+	pad := f.maxHalfWidth()
+	columns := opts.Boundary.columns(width, pad)
+	rows := opts.Boundary.rows(height, f.HalfHeight)
+
+	temp := make([]float64, len(data))
+	err := parallelBands(ctx, height, opts, func(start, end int) {
+		scratch := newExtremeScratch(width, pad)
+		for y := start; y < end; y++ {
+			half := f.HalfWidths[y]
+			scratch.filter(data[y*width:(y+1)*width], temp[y*width:(y+1)*width], half, isMin, missing, columns[pad-half:])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]float64, len(data))
+	strips := (width + columnStrip - 1) / columnStrip
+	err = parallelBands(ctx, strips, opts, func(start, end int) {
+		scratch := newColumnScratch(height, min(columnStrip, width), f.HalfHeight)
+		for strip := start; strip < end; strip++ {
+			x0 := strip * columnStrip
+			scratch.filter(temp, result, width, x0, min(columnStrip, width-x0), f.HalfHeight, isMin, missing, rows)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// extremeScratch holds the padded line and the van Herk/Gil-Werman prefix
+// and suffix buffers, sized for the longest padded line they are used for.
+type extremeScratch struct {
+	padded, prefix, suffix []float64
+}
+
+func newExtremeScratch(n, half int) *extremeScratch {
+	size := n + 2*half
+	return &extremeScratch{
+		padded: make([]float64, size),
+		prefix: make([]float64, size),
+		suffix: make([]float64, size),
+	}
+}
+
+// filter writes to out the extreme of src over windows of 2*half+1 samples
+// centered on each index. src is padded to the samples sources lists (see
+// Boundary.sources), and NaN, like a sample sources marks missing, is
+// replaced by missing so it never wins. Maxima are found as negated minima
+// of the negated samples, which is exact, so a single inlined comparison
+// serves both.
+//
+// The padded line is split into blocks of one window length; prefix holds
+// the running minimum from each block's start and suffix the running minimum
+// to its end, so every window, which spans at most two blocks, is the minimum
+// of one suffix and one prefix value.
+func (s *extremeScratch) filter(src, out []float64, half int, isMin bool, missing float64, sources []int) {
+	w, size := 2*half+1, len(out)+2*half
+	p, g, h := s.padded[:size], s.prefix[:size], s.suffix[:size]
+	sign := 1.0
+	if !isMin {
+		sign = -1
+	}
+	for i := range p {
+		v := missing
+		if j := sources[i]; j >= 0 && src[j] == src[j] {
+			v = src[j]
+		}
+		p[i] = sign * v
+	}
+	for start := 0; start < len(p); start += w {
+		end := min(start+w, len(p))
+		g[start] = p[start]
+		for i := start + 1; i < end; i++ {
+			g[i] = min(g[i-1], p[i])
+		}
+		h[end-1] = p[end-1]
+		for i := end - 2; i >= start; i-- {
+			h[i] = min(h[i+1], p[i])
+		}
+	}
+	for x := range out {
+		out[x] = sign * min(h[x], g[x+w-1])
+	}
+}
+
+// columnScratch runs the same algorithm down a strip of adjacent columns,
+// working a whole strip row at a time so memory is read sequentially. Its
+// buffers fit strips of up to the number of columns it was made for.
+type columnScratch struct {
+	extremeScratch
+}
+
+func newColumnScratch(height, columns, half int) *columnScratch {
+	size := (height + 2*half) * columns
+	return &columnScratch{
+		extremeScratch: extremeScratch{
+			padded: make([]float64, size),
+			prefix: make([]float64, size),
+			suffix: make([]float64, size),
+		},
+	}
+}
+
+// filter reads columns x0 up to x0+c of the row-major src grid of the given
+// width and writes their filtered values to the same place in out.
+func (s *columnScratch) filter(src, out []float64, width, x0, c, half int, isMin bool, missing float64, sources []int) {
+	w := 2*half + 1
+	height := len(src) / width
+	rows := height + 2*half
+	p, g, h := s.padded[:rows*c], s.prefix[:rows*c], s.suffix[:rows*c]
+	sign := 1.0
+	if !isMin {
+		sign = -1
+	}
+	for i := 0; i < rows; i++ {
+		dst := p[i*c : (i+1)*c]
+		y := sources[i]
+		if y < 0 {
+			for j := range dst {
+				dst[j] = sign * missing
+			}
+			continue
+		}
+		row := src[y*width+x0:][:c]
+		for j, v := range row {
+			if v != v {
+				v = missing
+			}
+			dst[j] = sign * v
+		}
+	}
+	for start := 0; start < rows; start += w {
+		end := min(start+w, rows)
+		copy(g[start*c:(start+1)*c], p[start*c:(start+1)*c])
+		for i := start + 1; i < end; i++ {
+			prev, cur, dst := g[(i-1)*c:i*c], p[i*c:(i+1)*c], g[i*c:(i+1)*c]
+			for j := range dst {
+				dst[j] = min(prev[j], cur[j])
+			}
+		}
+		copy(h[(end-1)*c:end*c], p[(end-1)*c:end*c])
+		for i := end - 2; i >= start; i-- {
+			next, cur, dst := h[(i+1)*c:(i+2)*c], p[i*c:(i+1)*c], h[i*c:(i+1)*c]
+			for j := range dst {
+				dst[j] = min(next[j], cur[j])
+			}
+		}
+	}
+	for y := 0; y < height; y++ {
+		left, right := h[y*c:(y+1)*c], g[(y+w-1)*c:(y+w)*c]
+		dst := out[y*width+x0:][:c]
+		for j := range dst {
+			dst[j] = sign * min(left[j], right[j])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package grid_to_isobands
+
+import (
+	"context"
+	"fmt"
+)
+
+// Unit names the unit of a grid's values.
+type Unit string
+
+const (
+	Kelvin     Unit = "K"
+	Celsius    Unit = "degC"
+	Fahrenheit Unit = "degF"
+
+	Pascal          Unit = "Pa"
+	Hectopascal     Unit = "hPa"
+	InchesOfMercury Unit = "inHg"
+
+	MetersPerSecond Unit = "m/s"
+	Knots           Unit = "kt"
+	MilesPerHour    Unit = "mph"
+
+	Millimeters Unit = "mm"
+	Inches      Unit = "in"
+)
+
+// unitProperty is the key of the unit in isoband properties.
+const unitProperty = `unit`
+
+// unitScale converts a unit to the base unit of its quantity: base = value *
+// factor + offset.
+type unitScale struct {
+	quantity string
+	factor   float64
+	offset   float64
+}
+
+var unitScales = map[Unit]unitScale{
+	Kelvin:     {quantity: `temperature`, factor: 1},
+	Celsius:    {quantity: `temperature`, factor: 1, offset: 273.15},
+	Fahrenheit: {quantity: `temperature`, factor: 5.0 / 9, offset: 273.15 - 32*5.0/9},
+
+	Pascal:          {quantity: `pressure`, factor: 1},
+	Hectopascal:     {quantity: `pressure`, factor: 100},
+	InchesOfMercury: {quantity: `pressure`, factor: 3386.389},
+
+	MetersPerSecond: {quantity: `speed`, factor: 1},
+	Knots:           {quantity: `speed`, factor: 1852.0 / 3600},
+	MilesPerHour:    {quantity: `speed`, factor: 0.44704},
+
+	Millimeters: {quantity: `length`, factor: 1},
+	Inches:      {quantity: `length`, factor: 25.4},
+}
+
+// unitAliases maps other common spellings, such as CF and GRIB units, onto
+// the units above.
+var unitAliases = map[Unit]Unit{
+	`kelvin`:            Kelvin,
+	`degK`:              Kelvin,
+	`C`:                 Celsius,
+	`°C`:                Celsius,
+	`celsius`:           Celsius,
+	`degree_Celsius`:    Celsius,
+	`F`:                 Fahrenheit,
+	`°F`:                Fahrenheit,
+	`fahrenheit`:        Fahrenheit,
+	`degree_Fahrenheit`: Fahrenheit,
+	`mb`:                Hectopascal,
+	`mbar`:              Hectopascal,
+	`m s-1`:             MetersPerSecond,
+	`m s**-1`:           MetersPerSecond,
+	`knots`:             Knots,
+	`kts`:               Knots,
+	`inch`:              Inches,
+}
+
+// canonical returns the unit u is another spelling of, or u itself.
+func (u Unit) canonical() Unit {
+	if alias, ok := unitAliases[u]; ok {
+		return alias
+	}
+	return u
+}
+
+// Conversion returns the factor and offset converting values in u to values
+// in to: value*factor + offset. It fails for unknown units and for units of
+// different quantities, such as K and hPa.
+func (u Unit) Conversion(to Unit) (factor, offset float64, err error) {
+	from, ok := unitScales[u.canonical()]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown unit %q", u)
+	}
+	target, ok := unitScales[to.canonical()]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if from.quantity != target.quantity {
+		return 0, 0, fmt.Errorf("can't convert %v %v to %v %v", from.quantity, u, target.quantity, to)
+	}
+	factor = from.factor / target.factor
+	offset = (from.offset - target.offset) / target.factor
+	return factor, offset, nil
+}
+
+// UnitTransformer converts the grid's values from one unit to another and
+// sets its Unit, so the isobands record it. from may be empty to convert
+// from the grid's Unit; otherwise the grid's Unit must be empty or match it,
+// allowing for other spellings such as "m s-1" for m/s.
+// Floor and Step in IsobandArgs apply after preprocessing, so they are given
+// in the new unit, as are the floor and ceiling of every band.
+func UnitTransformer(from, to Unit) GridTransformer {
+	return func(ctx context.Context, values *GridValues) error {
+		unit := from
+		if unit == "" {
+			unit = values.Unit
+		}
+		if unit == "" {
+			return fmt.Errorf("error converting to %v: the grid has no unit", to)
+		}
+		if values.Unit != "" && values.Unit.canonical() != unit.canonical() {
+			return fmt.Errorf("error converting from %v: the grid is in %v", unit, values.Unit)
+		}
+		factor, offset, err := unit.Conversion(to)
+		if err != nil {
+			return fmt.Errorf("error converting units: %w", err)
+		}
+		for i, v := range values.Values {
+			values.Values[i] = v*factor + offset
+		}
+		values.Unit = to
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+)
+
+func TestUnitConversion(t *testing.T) {
+	tests := []struct {
+		from, to grid_to_isobands.Unit
+		value    float64
+		expected float64
+	}{
+		{from: grid_to_isobands.Kelvin, to: grid_to_isobands.Fahrenheit, value: 273.15, expected: 32},
+		{from: grid_to_isobands.Fahrenheit, to: grid_to_isobands.Celsius, value: 212, expected: 100},
+		{from: `degree_Celsius`, to: grid_to_isobands.Kelvin, value: -40, expected: 233.15},
+		{from: grid_to_isobands.Hectopascal, to: grid_to_isobands.InchesOfMercury, value: 1013.25, expected: 29.921},
+		{from: grid_to_isobands.Pascal, to: `mb`, value: 101325, expected: 1013.25},
+		{from: `m s-1`, to: grid_to_isobands.Knots, value: 10, expected: 19.438},
+		{from: grid_to_isobands.MilesPerHour, to: grid_to_isobands.MetersPerSecond, value: 100, expected: 44.704},
+		{from: grid_to_isobands.Inches, to: grid_to_isobands.Millimeters, value: 2, expected: 50.8},
+	}
+	for _, test := range tests {
+		factor, offset, err := test.from.Conversion(test.to)
+		if err != nil {
+			t.Fatalf(`expected no error, got %v`, err)
+		}
+		if actual := test.value*factor + offset; math.Abs(actual-test.expected) > 1e-3 {
+			t.Fatalf(`expected %v %v %v, got %v`, test.value, test.from, test.expected, actual)
+		}
+	}
+	for _, bad := range [][2]grid_to_isobands.Unit{{grid_to_isobands.Kelvin, grid_to_isobands.Hectopascal}, {`furlong`, grid_to_isobands.Inches}} {
+		if _, _, err := bad[0].Conversion(bad[1]); err == nil {
+			t.Fatalf(`expected an error converting %v to %v, got nil`, bad[0], bad[1])
+		}
+	}
+}
+
+func TestUnitTransformer(t *testing.T) {
+	values := &grid_to_isobands.GridValues{
+		SizeX:    2,
+		SizeY:    1,
+		Values:   []float64{273.15, math.NaN()},
+		Geometry: grid_to_isobands.RegularLatLon{DLat: 1, DLon: 1},
+	}
+	if err := grid_to_isobands.UnitTransformer("", grid_to_isobands.Celsius)(context.Background(), values); err == nil {
+		t.Fatalf(`expected an error for a grid without a unit, got nil`)
+	}
+	if err := grid_to_isobands.UnitTransformer(grid_to_isobands.Kelvin, grid_to_isobands.Celsius)(context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if values.Values[0] != 0 || !math.IsNaN(values.Values[1]) || values.Unit != grid_to_isobands.Celsius {
+		t.Fatalf(`expected [0 NaN] degC, got %v %v`, values.Values, values.Unit)
+	}
+	// The grid is in degC now, not K.
+	if err := grid_to_isobands.UnitTransformer(grid_to_isobands.Kelvin, grid_to_isobands.Celsius)(context.Background(), values); err == nil {
+		t.Fatalf(`expected an error for a grid in another unit, got nil`)
+	}
+	if err := grid_to_isobands.UnitTransformer("", grid_to_isobands.Fahrenheit)(context.Background(), values); err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if math.Abs(values.Values[0]-32) > 1e-9 || values.Unit != grid_to_isobands.Fahrenheit {
+		t.Fatalf(`expected 32 degF, got %v %v`, values.Values[0], values.Unit)
+	}
+}
+
+func TestUnitOnEmptyIsobands(t *testing.T) {
+	args := &grid_to_isobands.IsobandArgs{
+		Grid: &grid_to_isobands.GridValues{
+			SizeX:  2,
+			SizeY:  1,
+			Values: []float64{math.NaN(), math.NaN()},
+			Lats:   []float64{10, 10},
+			Lons:   []float64{20, 21},
+			Unit:   grid_to_isobands.Celsius,
+		},
+		Step:      5,
+		AddlProps: map[string]any{`measure`: `temperature-surface`},
+	}
+	result, err := grid_to_isobands.IsobandsFromGrid(context.Background(), args)
+	if err != nil {
+		t.Fatalf(`expected no error, got %v`, err)
+	}
+	if len(result.Isobands.Features) != 0 {
+		t.Fatalf(`expected no features, got %v`, result.Isobands.Features)
+	}
+	properties := result.Isobands.Properties
+	if properties[`unit`] != `degC` || properties[`measure`] != `temperature-surface` {
+		t.Fatalf(`expected the unit and AddlProps, got %v`, properties)
+	}
+	if _, ok := args.AddlProps[`unit`]; ok {
+		t.Fatalf(`expected AddlProps to be left alone, got %v`, args.AddlProps)
+	}
+}
@@ -0,0 +1,233 @@
+package grid_to_isobands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors wrapped by ValidationError, for use with errors.Is.
+var (
+	ErrInvalidSize         = errors.New("invalid grid size")
+	ErrLengthMismatch      = errors.New("length does not match grid size")
+	ErrNonFiniteCoordinate = errors.New("non-finite coordinate")
+	ErrCoordinateRange     = errors.New("coordinate out of range")
+	ErrNonMonotonic        = errors.New("coordinates are not strictly monotonic")
+	ErrInvalidGeometry     = errors.New("invalid geometry")
+)
+
+// Longitudes may run from -360 to 360 so grids stored as 0..360, or crossing
+// the antimeridian past 180, validate without being wrapped first.
+const (
+	minLon = -360
+	maxLon = 360
+)
+
+// ValidationError describes the first problem Validate found with a grid.
+// Field names the offending GridValues field and Err is one of the sentinel
+// errors above.
+type ValidationError struct {
+	Field  string
+	Err    error
+	Detail string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid grid %v: %v: %v", e.Field, e.Err, e.Detail)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func invalid(field string, err error, format string, args ...any) *ValidationError {
+	return &ValidationError{Field: field, Err: err, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Validate checks that a grid can be preprocessed and contoured: the sizes
+// are positive and match the lengths of Values and the coordinates, every
+// coordinate is finite, latitudes lie in -90..90 and longitudes in -360..360,
+// and rectilinear coordinates are strictly monotonic (longitudes after
+// unwrapping across the antimeridian). Curvilinear per-point coordinates
+// may fold, e.g. around a pole, so for them Validate only rejects
+// neighbouring points that coincide. Problems are reported as a
+// *ValidationError.
+func (g *GridValues) Validate() error {
+	if g.SizeX <= 0 || g.SizeY <= 0 {
+		return invalid(`SizeX/SizeY`, ErrInvalidSize, "%dx%d", g.SizeX, g.SizeY)
+	}
+	if g.SizeX > math.MaxInt32 || g.SizeY > math.MaxInt32 || g.SizeX > math.MaxInt/g.SizeY {
+		return invalid(`SizeX/SizeY`, ErrInvalidSize, "%dx%d overflows", g.SizeX, g.SizeY)
+	}
+	size := g.SizeX * g.SizeY
+	if len(g.Values) != size {
+		return invalid(`Values`, ErrLengthMismatch, "expected %d values for a %dx%d grid, got %d", size, g.SizeX, g.SizeY, len(g.Values))
+	}
+
+	if g.Geometry == nil {
+		if len(g.Lats) != size {
+			return invalid(`Lats`, ErrLengthMismatch, "expected %d latitudes, got %d", size, len(g.Lats))
+		}
+		if len(g.Lons) != size {
+			return invalid(`Lons`, ErrLengthMismatch, "expected %d longitudes, got %d", size, len(g.Lons))
+		}
+		if err := validateCoordinates(`Lats`, g.Lats, -90, 90); err != nil {
+			return err
+		}
+		if err := validateCoordinates(`Lons`, g.Lons, minLon, maxLon); err != nil {
+			return err
+		}
+		if axes, ok := rectilinearAxes(g.Lats, g.Lons, g.SizeX, g.SizeY); ok {
+			return validateAxes(`Lats/Lons`, axes)
+		}
+		return validateDistinctNeighbours(g)
+	}
+
+	if len(g.Lats) != 0 && len(g.Lats) != size {
+		return invalid(`Lats`, ErrLengthMismatch, "expected 0 or %d latitudes alongside a Geometry, got %d", size, len(g.Lats))
+	}
+	if len(g.Lons) != 0 && len(g.Lons) != size {
+		return invalid(`Lons`, ErrLengthMismatch, "expected 0 or %d longitudes alongside a Geometry, got %d", size, len(g.Lons))
+	}
+	switch geometry := g.Geometry.(type) {
+	case RegularLatLon:
+		return validateRegular(geometry, g.SizeX, g.SizeY)
+	case LatLonAxes:
+		if len(geometry.Lats) != g.SizeY {
+			return invalid(`Geometry.Lats`, ErrLengthMismatch, "expected %d latitudes, got %d", g.SizeY, len(geometry.Lats))
+		}
+		if len(geometry.Lons) != g.SizeX {
+			return invalid(`Geometry.Lons`, ErrLengthMismatch, "expected %d longitudes, got %d", g.SizeX, len(geometry.Lons))
+		}
+		return validateAxes(`Geometry`, geometry)
+	case ProjectedGrid:
+		return validateProjected(geometry, g.SizeX, g.SizeY)
+	default:
+		expanded := &GridValues{SizeX: g.SizeX, SizeY: g.SizeY, Values: g.Values, Geometry: g.Geometry}
+		expanded.ExpandCoordinates()
+		if err := expanded.Validate(); err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				validationErr.Field = `Geometry`
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+func validateCoordinates(field string, values []float64, lo, hi float64) error {
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return invalid(field, ErrNonFiniteCoordinate, "%v at index %d", v, i)
+		}
+		if v < lo || v > hi {
+			return invalid(field, ErrCoordinateRange, "%v at index %d is outside %v..%v", v, i, lo, hi)
+		}
+	}
+	return nil
+}
+
+func validateAxes(field string, axes LatLonAxes) error {
+	if err := validateCoordinates(field+`.Lats`, axes.Lats, -90, 90); err != nil {
+		return err
+	}
+	if err := validateCoordinates(field+`.Lons`, axes.Lons, minLon, maxLon); err != nil {
+		return err
+	}
+	if i, ok := strictlyMonotonic(axes.Lats, false); !ok {
+		return invalid(field+`.Lats`, ErrNonMonotonic, "latitude %v at row %d", axes.Lats[i], i)
+	}
+	if i, ok := strictlyMonotonic(axes.Lons, true); !ok {
+		return invalid(field+`.Lons`, ErrNonMonotonic, "longitude %v at column %d", axes.Lons[i], i)
+	}
+	return nil
+}
+
+// strictlyMonotonic reports whether values consistently increase or
+// decrease, returning the first index that breaks the trend. Longitude steps
+// are taken the short way around, so an axis crossing the antimeridian
+// counts as monotonic.
+func strictlyMonotonic(values []float64, longitude bool) (int, bool) {
+	direction := 0.0
+	for i := 1; i < len(values); i++ {
+		step := values[i] - values[i-1]
+		if longitude {
+			step = math.Remainder(step, 360)
+		}
+		if step == 0 || (direction != 0 && math.Signbit(step) != math.Signbit(direction)) {
+			return i, false
+		}
+		direction = step
+	}
+	return 0, true
+}
+
+func validateRegular(g RegularLatLon, sizeX, sizeY int) error {
+	for _, v := range []float64{g.Lat0, g.Lon0, g.DLat, g.DLon} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return invalid(`Geometry`, ErrNonFiniteCoordinate, "%+v", g)
+		}
+	}
+	if (g.DLat == 0 && sizeY > 1) || (g.DLon == 0 && sizeX > 1) {
+		return invalid(`Geometry`, ErrNonMonotonic, "zero step in %+v", g)
+	}
+	for _, lat := range []float64{g.Lat0, g.Lat0 + float64(sizeY-1)*g.DLat} {
+		if lat < -90 || lat > 90 {
+			return invalid(`Geometry`, ErrCoordinateRange, "latitude %v is outside -90..90", lat)
+		}
+	}
+	for _, lon := range []float64{g.Lon0, g.Lon0 + float64(sizeX-1)*g.DLon} {
+		if lon < minLon || lon > maxLon {
+			return invalid(`Geometry`, ErrCoordinateRange, "longitude %v is outside %v..%v", lon, minLon, maxLon)
+		}
+	}
+	if span := math.Abs(float64(sizeX-1) * g.DLon); span >= 360 {
+		return invalid(`Geometry`, ErrCoordinateRange, "columns span %v degrees of longitude", span)
+	}
+	return nil
+}
+
+func validateProjected(g ProjectedGrid, sizeX, sizeY int) error {
+	if g.Projection == nil {
+		return invalid(`Geometry.Projection`, ErrInvalidGeometry, "projected grid has no projection")
+	}
+	for _, v := range []float64{g.X0, g.Y0, g.DX, g.DY} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return invalid(`Geometry`, ErrNonFiniteCoordinate, "origin %v,%v step %v,%v", g.X0, g.Y0, g.DX, g.DY)
+		}
+	}
+	if (g.DX == 0 && sizeX > 1) || (g.DY == 0 && sizeY > 1) {
+		return invalid(`Geometry`, ErrNonMonotonic, "zero step %v,%v", g.DX, g.DY)
+	}
+	for _, corner := range [][2]int{{0, 0}, {sizeX - 1, 0}, {0, sizeY - 1}, {sizeX - 1, sizeY - 1}} {
+		lat, lon := g.LatLon(corner[0], corner[1])
+		if math.IsNaN(lat) || math.IsNaN(lon) || math.IsInf(lat, 0) || math.IsInf(lon, 0) {
+			return invalid(`Geometry`, ErrNonFiniteCoordinate, "corner %d,%d projects to %v,%v", corner[0], corner[1], lat, lon)
+		}
+		if lat < -90 || lat > 90 {
+			return invalid(`Geometry`, ErrCoordinateRange, "corner %d,%d has latitude %v", corner[0], corner[1], lat)
+		}
+	}
+	return nil
+}
+
+// validateDistinctNeighbours rejects curvilinear grids where two adjacent
+// points share a location, which collapses the cells between them.
+func validateDistinctNeighbours(g *GridValues) error {
+	same := func(i, j int) bool {
+		return g.Lats[i] == g.Lats[j] && math.Remainder(g.Lons[i]-g.Lons[j], 360) == 0
+	}
+	for y := 0; y < g.SizeY; y++ {
+		for x := 0; x < g.SizeX; x++ {
+			i := y*g.SizeX + x
+			if x > 0 && same(i, i-1) {
+				return invalid(`Lats/Lons`, ErrNonMonotonic, "points %d and %d coincide", i-1, i)
+			}
+			if y > 0 && same(i, i-g.SizeX) {
+				return invalid(`Lats/Lons`, ErrNonMonotonic, "points %d and %d coincide", i-g.SizeX, i)
+			}
+		}
+	}
+	return nil
+}
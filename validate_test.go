@@ -0,0 +1,117 @@
+package grid_to_isobands_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/skysparq/grid-to-isobands"
+)
+
+func TestValidate(t *testing.T) {
+	regular := grid_to_isobands.RegularLatLon{Lat0: 50, Lon0: -100, DLat: -0.5, DLon: 0.25}
+	tests := []struct {
+		name     string
+		grid     *grid_to_isobands.GridValues
+		expected error
+	}{
+		{
+			name:     `valid regular`,
+			grid:     &grid_to_isobands.GridValues{SizeX: 3, SizeY: 2, Values: make([]float64, 6), Geometry: regular},
+			expected: nil,
+		},
+		{
+			name: `valid across antimeridian`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Lats: []float64{10, 10, 10, 11, 11, 11},
+				Lons: []float64{179, -180, -179, 179, -180, -179},
+			},
+			expected: nil,
+		},
+		{
+			name:     `zero size`,
+			grid:     &grid_to_isobands.GridValues{SizeX: 0, SizeY: 2, Geometry: regular},
+			expected: grid_to_isobands.ErrInvalidSize,
+		},
+		{
+			name:     `short values`,
+			grid:     &grid_to_isobands.GridValues{SizeX: 3, SizeY: 2, Values: make([]float64, 5), Geometry: regular},
+			expected: grid_to_isobands.ErrLengthMismatch,
+		},
+		{
+			name: `short lons`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Lats: []float64{10, 10, 10, 11, 11, 11},
+				Lons: []float64{1, 2, 3, 1, 2},
+			},
+			expected: grid_to_isobands.ErrLengthMismatch,
+		},
+		{
+			name: `NaN latitude`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Lats: []float64{10, 10, 10, 11, math.NaN(), 11},
+				Lons: []float64{1, 2, 3, 1, 2, 3},
+			},
+			expected: grid_to_isobands.ErrNonFiniteCoordinate,
+		},
+		{
+			name: `latitude past the pole`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Geometry: grid_to_isobands.RegularLatLon{Lat0: 89.75, Lon0: 0, DLat: 0.5, DLon: 1},
+			},
+			expected: grid_to_isobands.ErrCoordinateRange,
+		},
+		{
+			name: `unsorted axis`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Geometry: grid_to_isobands.LatLonAxes{Lats: []float64{10, 11}, Lons: []float64{1, 3, 2}},
+			},
+			expected: grid_to_isobands.ErrNonMonotonic,
+		},
+		{
+			name: `axis length`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Geometry: grid_to_isobands.LatLonAxes{Lats: []float64{10, 11}, Lons: []float64{1, 2}},
+			},
+			expected: grid_to_isobands.ErrLengthMismatch,
+		},
+		{
+			name: `projected without projection`,
+			grid: &grid_to_isobands.GridValues{
+				SizeX: 3, SizeY: 2, Values: make([]float64, 6),
+				Geometry: grid_to_isobands.ProjectedGrid{DX: 1, DY: 1},
+			},
+			expected: grid_to_isobands.ErrInvalidGeometry,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.grid.Validate()
+			if !errors.Is(err, test.expected) {
+				t.Fatalf(`expected %v, got %v`, test.expected, err)
+			}
+			var validationErr *grid_to_isobands.ValidationError
+			if err != nil && !errors.As(err, &validationErr) {
+				t.Fatalf(`expected a *ValidationError, got %T`, err)
+			}
+		})
+	}
+}
+
+func TestIsobandsFromGridValidates(t *testing.T) {
+	args := &grid_to_isobands.IsobandArgs{
+		Grid: &grid_to_isobands.GridValues{SizeX: 3, SizeY: 2, Values: make([]float64, 4)},
+		Step: 1,
+	}
+	_, err := grid_to_isobands.IsobandsFromGrid(context.Background(), args)
+	if !errors.Is(err, grid_to_isobands.ErrLengthMismatch) {
+		t.Fatalf(`expected %v, got %v`, grid_to_isobands.ErrLengthMismatch, err)
+	}
+}